@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// Mouse triggers of row actions
+const (
+	triggerLeft       = "left"
+	triggerMiddle     = "middle"
+	triggerRight      = "right"
+	triggerDouble     = "double"
+	triggerScrollUp   = "scroll-up"
+	triggerScrollDown = "scroll-down"
+)
+
+// Triggers in the order of the actions editor
+var triggers = []string{triggerLeft, triggerMiddle, triggerRight, triggerDouble, triggerScrollUp, triggerScrollDown}
+
+// IDs of built-in rows, in the window order
+var builtInRows = []string{rowCli, rowBrightness, rowVolume, rowUser, rowWifi, rowInterface, rowBluetooth, rowBattery}
+
+// Built-in row actions; `actions` in preferences.json and templates override them, an empty string or "@none"
+// disables one
+var defaultActions = map[string]map[string]string{
+	rowVolume: {
+		triggerMiddle:     "@mute",
+		triggerScrollUp:   "@volume +5",
+		triggerScrollDown: "@volume -5",
+	},
+	rowBrightness: {
+		triggerScrollUp:   "@brightness +5",
+		triggerScrollDown: "@brightness -5",
+	},
+	rowWifi: {
+		triggerRight: "@copy-ip",
+	},
+}
+
+// Returns actions of a row by trigger: built-in ones, the custom row or button command (as the left click action),
+// then these from preferences.json, and these from the template, each overriding the previous ones. `id` is
+// a built-in row ID, or customRowID / customButtonID of a custom entry. `@copy-ip` on the Wi-Fi row copies
+// the address of the wireless interface, unless given another one.
+func rowActions(id, command string, own map[string]string) map[string]string {
+	actions := make(map[string]string)
+	for trigger, action := range defaultActions[id] {
+		actions[trigger] = action
+	}
+	if command != "" {
+		actions[triggerLeft] = command
+	}
+	for _, layer := range []map[string]string{settings.Preferences.Actions[id], own} {
+		for trigger, action := range layer {
+			if action == "" || action == "@none" {
+				delete(actions, trigger)
+			} else {
+				actions[trigger] = action
+			}
+		}
+	}
+	if id == rowWifi {
+		if name := wifiInterface(); name != "" {
+			for trigger, action := range actions {
+				if action == "@copy-ip" {
+					actions[trigger] = "@copy-ip " + name
+				}
+			}
+		}
+	}
+	return actions
+}
+
+// Runs a row action: a command, or a built-in one starting with "@"
+func runAction(action string) {
+	if action == "" {
+		return
+	}
+	if !strings.HasPrefix(action, "@") {
+		launchCommand(action)
+		return
+	}
+
+	fields := strings.Fields(action)
+	arg := ""
+	if len(fields) > 1 {
+		arg = fields[1]
+	}
+	var err error
+	switch fields[0] {
+	case "@mute":
+		err = adjustVolume("toggle-mute")
+	case "@volume":
+		err = adjustVolume(arg)
+	case "@brightness":
+		err = adjustBrightness(arg)
+	case "@copy-ip":
+		err = copyAddress(arg)
+	case "@lock", "@logout", "@suspend", "@hibernate", "@reboot", "@poweroff":
+		err = runPowerAction(fields[0])
+	default:
+		err = fmt.Errorf("unknown action '%s'", fields[0])
+	}
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if volRow != nil && (fields[0] == "@mute" || fields[0] == "@volume") {
+		updateVolumeRow()
+	}
+	if briRow != nil && fields[0] == "@brightness" {
+		updateBrightnessRow()
+	}
+}
+
+// Copies IP address of the given interface (of the one the default route goes through, if none given)
+// to the clipboard
+func copyAddress(name string) error {
+	if name == "" {
+		name = defaultInterface()
+	}
+	up, ip := interfaceIsUp(name)
+	if !up || ip == "" {
+		return fmt.Errorf("no address on interface '%s'", name)
+	}
+	clipboard, err := gtk.ClipboardGet(gdk.SELECTION_CLIPBOARD)
+	if err != nil {
+		return err
+	}
+	clipboard.SetText(ip)
+	clipboard.Store()
+	fmt.Printf("Copied %s\n", ip)
+	return nil
+}
+
+// Double click time from gtk settings
+func doubleClickMillis() uint {
+	gtkSettings, err := gtk.SettingsGetDefault()
+	if err == nil {
+		if t, err := gtkSettings.GetProperty("gtk-double-click-time"); err == nil {
+			if millis, ok := t.(int); ok && millis > 0 {
+				return uint(millis)
+			}
+		}
+	}
+	return 400
+}
+
+// Connects mouse buttons and scroll to row actions; `left` runs the left click action. Buttons handle left clicks
+// on their own, so `left` is nil for them, unless they have a double click action. If there is a double click action,
+// the left click one waits to see if a second click follows.
+func connectActions(widget *gtk.Widget, actions map[string]string, left func()) {
+	widget.AddEvents(int(gdk.BUTTON_PRESS_MASK | gdk.SCROLL_MASK))
+
+	var pendingClick glib.SourceHandle
+	widget.Connect("button-press-event", func(_ interface{}, event *gdk.Event) bool {
+		noteActivity()
+		btn := gdk.EventButtonNewFromEvent(event)
+		if btn.Type() == gdk.EVENT_2BUTTON_PRESS {
+			if pendingClick != 0 {
+				glib.SourceRemove(pendingClick)
+				pendingClick = 0
+				runAction(actions[triggerDouble])
+				return true
+			}
+			return false
+		}
+
+		trigger := ""
+		switch btn.Button() {
+		case gdk.BUTTON_PRIMARY:
+			if left == nil {
+				return false
+			}
+			trigger = triggerLeft
+		case gdk.BUTTON_MIDDLE:
+			trigger = triggerMiddle
+		case gdk.BUTTON_SECONDARY:
+			trigger = triggerRight
+		}
+		if trigger == triggerLeft && actions[triggerDouble] != "" {
+			// the second press of a double click
+			if pendingClick != 0 {
+				return true
+			}
+			pendingClick = glib.TimeoutAdd(doubleClickMillis(), func() bool {
+				pendingClick = 0
+				left()
+				return false
+			})
+			return true
+		}
+		if actions[trigger] == "" {
+			return false
+		}
+		if trigger == triggerLeft {
+			left()
+		} else {
+			runAction(actions[trigger])
+		}
+		return true
+	})
+
+	widget.Connect("scroll-event", func(_ interface{}, event *gdk.Event) bool {
+		noteActivity()
+		scroll := gdk.EventScrollNewFromEvent(event)
+		trigger := ""
+		switch scroll.Direction() {
+		case gdk.SCROLL_UP:
+			trigger = triggerScrollUp
+		case gdk.SCROLL_DOWN:
+			trigger = triggerScrollDown
+		}
+		if actions[trigger] == "" {
+			return false
+		}
+		runAction(actions[trigger])
+		return true
+	})
+}
+
+// Moves on-click-* commands of preferences.json older than actions to left click actions, unless set there
+func migrateOnClick(p *Preferences) {
+	old := map[string]*string{
+		rowUser:      &p.OnClickUser,
+		rowWifi:      &p.OnClickWifi,
+		rowBluetooth: &p.OnClickBluetooth,
+		rowBattery:   &p.OnClickBattery,
+		rowInterface: &p.OnClickInterface,
+	}
+	for id, command := range old {
+		if *command == "" {
+			continue
+		}
+		if p.Actions == nil {
+			p.Actions = make(map[string]map[string]string)
+		}
+		if p.Actions[id] == nil {
+			p.Actions[id] = make(map[string]string)
+		}
+		if _, ok := p.Actions[id][triggerLeft]; !ok {
+			p.Actions[id][triggerLeft] = *command
+		}
+		*command = ""
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRowActions(t *testing.T) {
+	preferences := settings.Preferences.Actions
+	defer func() {
+		settings.Preferences.Actions = preferences
+	}()
+	settings.Preferences.Actions = map[string]map[string]string{
+		rowVolume:                  {triggerMiddle: "pavucontrol"},
+		rowBrightness:              {triggerScrollUp: "@none", triggerScrollDown: ""},
+		customRowID("volume"):      {triggerRight: "pavucontrol"},
+		customButtonID("Exit"):     {triggerDouble: "@poweroff"},
+		customRowID("Terminal"):    {triggerLeft: "foot"},
+		customButtonID("Terminal"): {triggerLeft: "alacritty"},
+	}
+
+	tests := []struct {
+		name    string
+		id      string
+		command string
+		own     map[string]string
+		want    map[string]string
+	}{
+		{
+			name: "defaults overridden by preferences",
+			id:   rowVolume,
+			want: map[string]string{
+				triggerMiddle: "pavucontrol", triggerScrollUp: "@volume +5", triggerScrollDown: "@volume -5"},
+		},
+		{
+			name: "defaults disabled",
+			id:   rowBrightness,
+			want: map[string]string{},
+		},
+		{
+			name: "no actions",
+			id:   rowBattery,
+			want: map[string]string{},
+		},
+		{
+			name:    "custom row named as a built-in one",
+			id:      customRowID("volume"),
+			command: "amixer set Master toggle",
+			want:    map[string]string{triggerLeft: "amixer set Master toggle", triggerRight: "pavucontrol"},
+		},
+		{
+			name:    "custom button",
+			id:      customButtonID("Exit"),
+			command: "swaymsg exit",
+			want:    map[string]string{triggerLeft: "swaymsg exit", triggerDouble: "@poweroff"},
+		},
+		{
+			name:    "template overrides preferences",
+			id:      customRowID("Terminal"),
+			command: "xterm",
+			own:     map[string]string{triggerLeft: "kitty", triggerMiddle: "foot --server"},
+			want:    map[string]string{triggerLeft: "kitty", triggerMiddle: "foot --server"},
+		},
+		{
+			name:    "rows and buttons of the same name apart",
+			id:      customButtonID("Terminal"),
+			command: "xterm",
+			want:    map[string]string{triggerLeft: "alacritty"},
+		},
+		{
+			name:    "template disables the command",
+			id:      customRowID("Setup"),
+			command: "nwg-shell-config",
+			own:     map[string]string{triggerLeft: "@none"},
+			want:    map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rowActions(tt.id, tt.command, tt.own)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRowActionsWifiAddress(t *testing.T) {
+	want := "@copy-ip"
+	if name := wifiInterface(); name != "" {
+		want += " " + name
+	}
+	if got := rowActions(rowWifi, "", nil)[triggerRight]; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	own := map[string]string{triggerRight: "@copy-ip eth0"}
+	if got := rowActions(rowWifi, "", own)[triggerRight]; got != "@copy-ip eth0" {
+		t.Errorf("got %q, want interface given kept", got)
+	}
+}
+
+func TestMigrateOnClick(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Preferences
+		want map[string]map[string]string
+	}{
+		{
+			name: "nothing to migrate",
+			p:    Preferences{},
+			want: nil,
+		},
+		{
+			name: "commands moved to left click actions",
+			p:    Preferences{OnClickWifi: "nm-connection-editor", OnClickBattery: "gnome-power-statistics"},
+			want: map[string]map[string]string{
+				rowWifi:    {triggerLeft: "nm-connection-editor"},
+				rowBattery: {triggerLeft: "gnome-power-statistics"},
+			},
+		},
+		{
+			name: "other triggers kept",
+			p: Preferences{OnClickUser: "lxqt-about",
+				Actions: map[string]map[string]string{rowUser: {triggerRight: "foot"}}},
+			want: map[string]map[string]string{rowUser: {triggerLeft: "lxqt-about", triggerRight: "foot"}},
+		},
+		{
+			name: "left click action set already",
+			p: Preferences{OnClickBluetooth: "blueman-manager", OnClickInterface: "nmtui",
+				Actions: map[string]map[string]string{
+					rowBluetooth: {triggerLeft: "blueberry"},
+					rowInterface: {triggerLeft: ""},
+				}},
+			want: map[string]map[string]string{
+				rowBluetooth: {triggerLeft: "blueberry"},
+				rowInterface: {triggerLeft: ""},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrateOnClick(&tt.p)
+			if !reflect.DeepEqual(tt.p.Actions, tt.want) {
+				t.Errorf("got %v, want %v", tt.p.Actions, tt.want)
+			}
+			if tt.p.OnClickUser != "" || tt.p.OnClickWifi != "" || tt.p.OnClickBluetooth != "" ||
+				tt.p.OnClickBattery != "" || tt.p.OnClickInterface != "" {
+				t.Errorf("on-click commands left: %+v", tt.p)
+			}
+		})
+	}
+}
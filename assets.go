@@ -0,0 +1,107 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Installation prefix; may be overridden at build time with `-ldflags "-X main.prefix=/usr/local"`
+var prefix = "/usr"
+
+// Default configs, the preferences window definition and both icon sets, built into the binary.
+// Used whenever the files can't be found in system data dirs (e.g. on `go run`).
+//
+//go:embed configs preferences.glade icons_light icons_dark
+var assets embed.FS
+
+// Returns nwgocc system data dirs in lookup order: $XDG_DATA_DIRS first, then the build-time prefix
+func sharedDataDirs() []string {
+	dirs := os.Getenv("XDG_DATA_DIRS")
+	if dirs == "" {
+		dirs = "/usr/local/share:/usr/share"
+	}
+	var output []string
+	for _, dir := range strings.Split(dirs, ":") {
+		if dir != "" {
+			output = append(output, filepath.Join(dir, "nwgocc"))
+		}
+	}
+	prefixDir := filepath.Join(prefix, "share", "nwgocc")
+	for _, dir := range output {
+		if dir == prefixDir {
+			return output
+		}
+	}
+	return append(output, prefixDir)
+}
+
+// Returns path to the installed copy of a default file or dir, or "" if not installed
+func sharedPath(name string) string {
+	for _, dir := range sharedDataDirs() {
+		p := filepath.Join(dir, name)
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return ""
+}
+
+// Returns path to a default file inside the embedded FS; config files live in the `configs` subdir
+func embeddedPath(name string) string {
+	p := path.Join("configs", name)
+	if _, err := fs.Stat(assets, p); err == nil {
+		return p
+	}
+	return name
+}
+
+// Returns content of a default file: the installed one if found, the embedded one otherwise
+func readAsset(name string) ([]byte, error) {
+	if p := sharedPath(name); p != "" {
+		return ioutil.ReadFile(p)
+	}
+	return assets.ReadFile(embeddedPath(name))
+}
+
+// Lists file names of a default dir (e.g. `icons_light`), installed or embedded
+func listAssets(dir string) []string {
+	var output []string
+	if p := sharedPath(dir); p != "" {
+		files, err := ioutil.ReadDir(p)
+		if err == nil {
+			for _, file := range files {
+				output = append(output, file.Name())
+			}
+			return output
+		}
+	}
+	files, err := assets.ReadDir(dir)
+	check(err)
+	for _, file := range files {
+		output = append(output, file.Name())
+	}
+	return output
+}
+
+// Copies a default file to dst, if dst not found; installed file preferred over the embedded one
+func copyAsset(name, dst string) error {
+	if p := sharedPath(name); p != "" {
+		return copyFile(p, dst)
+	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		return err
+	}
+	bytes, err := assets.ReadFile(embeddedPath(name))
+	if err != nil {
+		return err
+	}
+	fmt.Println("Copying file:", dst)
+
+	return ioutil.WriteFile(dst, bytes, 0644)
+}
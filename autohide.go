@@ -0,0 +1,95 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// Grace period after the window lost focus, before closing it: focus may have gone to a window of ours
+const focusOutGraceMillis = 500
+
+// Last pointer or keyboard activity in the main window
+var lastActivity time.Time
+
+// Timer checking for idle time while the window is shown
+var idleTimer glib.SourceHandle
+
+// Checks if any other window of ours (preferences, editors, dialogs, popup menus) is shown
+func otherWindowVisible() bool {
+	visible := false
+	list := gtk.WindowListToplevels()
+	if list == nil {
+		return false
+	}
+	list.Foreach(func(item interface{}) {
+		w, ok := item.(*gtk.Window)
+		if !ok || !w.IsVisible() || w.Native() == win.Native() {
+			return
+		}
+		if osdWindow != nil && w.Native() == osdWindow.Native() {
+			return
+		}
+		visible = true
+	})
+	return visible
+}
+
+// Postpones auto-hide
+func noteActivity() {
+	lastActivity = time.Now()
+}
+
+// Closes the main window on focus loss, and after `auto_hide_seconds` without pointer or keyboard activity,
+// if turned on in preferences
+func setupAutoHide() {
+	win.Connect("focus-out-event", func() bool {
+		if settings.Preferences.CloseOnFocusOut {
+			glib.TimeoutAdd(focusOutGraceMillis, func() bool {
+				if win.IsVisible() && !win.IsActive() && !otherWindowVisible() {
+					closeWindow()
+				}
+				return false
+			})
+		}
+		return false
+	})
+
+	// rows and buttons stop clicks and scroll they handle from reaching the window, so connectActions notes
+	// activity too
+	win.AddEvents(int(gdk.POINTER_MOTION_MASK | gdk.BUTTON_PRESS_MASK | gdk.KEY_PRESS_MASK | gdk.SCROLL_MASK))
+	for _, signal := range []string{"motion-notify-event", "button-press-event", "key-press-event", "scroll-event"} {
+		win.Connect(signal, func() bool {
+			noteActivity()
+			return false
+		})
+	}
+
+	win.Connect("show", func() {
+		lastActivity = time.Now()
+		if idleTimer != 0 {
+			glib.SourceRemove(idleTimer)
+			idleTimer = 0
+		}
+		if settings.Preferences.AutoHideSeconds <= 0 {
+			return
+		}
+		idleTimer = glib.TimeoutAdd(1000, func() bool {
+			if !win.IsVisible() {
+				idleTimer = 0
+				return false
+			}
+			if otherWindowVisible() {
+				lastActivity = time.Now()
+			}
+			if time.Since(lastActivity) >= time.Duration(settings.Preferences.AutoHideSeconds)*time.Second {
+				idleTimer = 0
+				closeWindow()
+				return false
+			}
+			return true
+		})
+	})
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// barItem is what a single status section looks like on a bar
+type barItem struct {
+	name       string
+	text       string
+	icon       string
+	class      string
+	percentage int // -1 if not applicable
+	urgent     bool
+}
+
+// Waybar custom module output, see `man waybar-custom`
+type waybarOutput struct {
+	Text       string   `json:"text"`
+	Alt        string   `json:"alt,omitempty"`
+	Tooltip    string   `json:"tooltip"`
+	Class      []string `json:"class"`
+	Percentage int      `json:"percentage"`
+}
+
+// i3bar protocol block, see https://i3wm.org/docs/i3bar-protocol.html
+type i3barBlock struct {
+	Name     string `json:"name"`
+	FullText string `json:"full_text"`
+	Urgent   bool   `json:"urgent,omitempty"`
+	Icon     string `json:"_icon,omitempty"`
+	Class    string `json:"_class,omitempty"`
+}
+
+// Turns status sections into bar items, in the statusSections order
+func barItems(s Status) []barItem {
+	var items []barItem
+	if s.Battery != nil {
+		item := barItem{name: "battery", text: fmt.Sprintf("%d%%", s.Battery.Percentage),
+			icon: batteryIcon(s.Battery.Percentage), percentage: s.Battery.Percentage}
+		switch {
+		case s.Battery.Ac:
+			item.class = "charging"
+		case s.Battery.Percentage <= settings.Notifications.BatteryCritical:
+			item.class = "critical"
+			item.urgent = true
+		case s.Battery.Percentage <= settings.Notifications.BatteryLow:
+			// as Waybar battery states
+			item.class = "warning"
+		default:
+			item.class = "discharging"
+		}
+		items = append(items, item)
+	}
+	if s.Wifi != nil {
+		item := barItem{name: "wifi", text: s.Wifi.Ssid, icon: settings.Icons.WifiOn, class: "connected",
+			percentage: -1}
+		if !s.Wifi.Connected {
+			item.text, item.icon, item.class = "disconnected", settings.Icons.WifiOff, "disconnected"
+		}
+		items = append(items, item)
+	}
+	if s.Bluetooth != nil {
+		item := barItem{name: "bt", text: s.Bluetooth.Name, icon: settings.Icons.BtOn, class: "on", percentage: -1}
+		if !s.Bluetooth.Powered {
+			item.text, item.icon, item.class = "off", settings.Icons.BtOff, "off"
+		}
+		items = append(items, item)
+	}
+	if s.Net != nil {
+		item := barItem{name: "net", icon: settings.Icons.NetworkConnected, class: "up", percentage: -1}
+		switch {
+		case s.Net.Interface != "" && s.Net.Up:
+			item.text = fmt.Sprintf("%s: %s", s.Net.Interface, s.Net.Address)
+		case s.Net.Interface != "":
+			item.text = fmt.Sprintf("%s: down", s.Net.Interface)
+		case s.Net.DefaultInterface != "":
+			item.text = s.Net.DefaultInterface
+		default:
+			item.text = "down"
+		}
+		if strings.HasSuffix(item.text, "down") {
+			item.icon, item.class = settings.Icons.NetworkDisonnected, "down"
+		}
+		items = append(items, item)
+	}
+	if s.Volume != nil {
+		item := barItem{name: "volume", text: fmt.Sprintf("%d%%", s.Volume.Volume),
+			icon: volumeIcon(s.Volume.Volume, s.Volume.Muted), class: "unmuted", percentage: s.Volume.Volume}
+		if s.Volume.Muted {
+			item.text, item.class = "muted", "muted"
+		}
+		items = append(items, item)
+	}
+	if s.Brightness != nil {
+		items = append(items, barItem{name: "brightness", text: fmt.Sprintf("%d%%", s.Brightness.Brightness),
+			icon: brightnessIcon(s.Brightness.Brightness), percentage: s.Brightness.Brightness})
+	}
+	return items
+}
+
+// Returns a single line in the Waybar custom module JSON format. Icon name of the first section goes to `alt`,
+// to be used as a `format-icons` key; classes are prefixed with section names, e.g. "battery-charging".
+func waybarLine(s Status) string {
+	items := barItems(s)
+	out := waybarOutput{Tooltip: strings.TrimSuffix(s.String(), "\n"), Class: []string{}}
+	var texts []string
+	for i, item := range items {
+		texts = append(texts, item.text)
+		if item.class != "" {
+			out.Class = append(out.Class, fmt.Sprintf("%s-%s", item.name, item.class))
+		}
+		if item.urgent {
+			out.Class = append(out.Class, "urgent")
+		}
+		if i == 0 {
+			out.Alt = item.icon
+			if item.percentage >= 0 {
+				out.Percentage = item.percentage
+			}
+		}
+	}
+	out.Text = strings.Join(texts, " ")
+	bytes, _ := json.Marshal(out)
+	return string(bytes)
+}
+
+// Returns a status line (array of blocks) in the i3bar protocol
+func i3barLine(s Status) string {
+	blocks := []i3barBlock{}
+	for _, item := range barItems(s) {
+		blocks = append(blocks, i3barBlock{Name: item.name, FullText: fmt.Sprintf("%s: %s", item.name, item.text),
+			Urgent: item.urgent, Icon: item.icon, Class: item.class})
+	}
+	bytes, _ := json.Marshal(blocks)
+	return string(bytes)
+}
+
+// Handles `nwgocc --bar waybar|i3bar [section...]`: prints status of given sections (all by default) on every change,
+// until killed. Battery gets refreshed every `refresh_slow_seconds`, other sections every `refresh_fast_millis`.
+func runBar(format string, args []string) {
+	var line func(Status) string
+	switch format {
+	case "waybar":
+		line = waybarLine
+	case "i3bar":
+		line = i3barLine
+	default:
+		fmt.Printf("Unknown bar format '%s', expected 'waybar' or 'i3bar'\n", format)
+		os.Exit(1)
+	}
+
+	var sections []string
+	for _, arg := range args {
+		if arg == "all" {
+			sections = statusSections
+			continue
+		}
+		checkSection(arg)
+		sections = append(sections, arg)
+	}
+
+	loadSettingsOrDefaults()
+
+	if format == "i3bar" {
+		fmt.Println(`{"version": 1}`)
+		fmt.Println("[")
+	}
+	last := ""
+	pollStatus(sections, func(status Status) {
+		current := line(status)
+		if current != last {
+			if format == "i3bar" && last != "" {
+				fmt.Printf(",%s\n", current)
+			} else {
+				fmt.Println(current)
+			}
+			last = current
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package main
+
+import "testing"
+
+func TestBarLines(t *testing.T) {
+	settings.Icons = Icons{BatteryFull: "bat-full", BatteryGood: "bat-good", BatteryLow: "bat-low",
+		BatteryEmpty: "bat-empty", WifiOn: "wifi-on", WifiOff: "wifi-off", VolumeMuted: "vol-muted",
+		VolumeLow: "vol-low", VolumeMedium: "vol-medium", VolumeHigh: "vol-high"}
+	settings.Notifications.BatteryLow = 15
+	settings.Notifications.BatteryCritical = 5
+
+	tests := []struct {
+		name   string
+		status Status
+		waybar string
+		i3bar  string
+	}{
+		{
+			name:   "empty",
+			status: Status{},
+			waybar: `{"text":"","tooltip":"","class":[],"percentage":0}`,
+			i3bar:  `[]`,
+		},
+		{
+			name:   "battery charging",
+			status: Status{Battery: &BatteryStatus{Percentage: 80, Ac: true}},
+			waybar: `{"text":"80%","alt":"bat-good","tooltip":"battery: 80% (ac) ","class":["battery-charging"],"percentage":80}`,
+			i3bar:  `[{"name":"battery","full_text":"battery: 80%","_icon":"bat-good","_class":"charging"}]`,
+		},
+		{
+			name:   "battery above low level",
+			status: Status{Battery: &BatteryStatus{Percentage: 16, Status: "Discharging"}},
+			waybar: `{"text":"16%","alt":"bat-empty","tooltip":"battery: 16% (battery) Discharging","class":["battery-discharging"],"percentage":16}`,
+			i3bar:  `[{"name":"battery","full_text":"battery: 16%","_icon":"bat-empty","_class":"discharging"}]`,
+		},
+		{
+			name:   "battery low",
+			status: Status{Battery: &BatteryStatus{Percentage: 15, Status: "Discharging"}},
+			waybar: `{"text":"15%","alt":"bat-empty","tooltip":"battery: 15% (battery) Discharging","class":["battery-warning"],"percentage":15}`,
+			i3bar:  `[{"name":"battery","full_text":"battery: 15%","_icon":"bat-empty","_class":"warning"}]`,
+		},
+		{
+			name:   "battery critical",
+			status: Status{Battery: &BatteryStatus{Percentage: 5, Status: "Discharging"}},
+			waybar: `{"text":"5%","alt":"bat-empty","tooltip":"battery: 5% (battery) Discharging","class":["battery-critical","urgent"],"percentage":5}`,
+			i3bar:  `[{"name":"battery","full_text":"battery: 5%","urgent":true,"_icon":"bat-empty","_class":"critical"}]`,
+		},
+		{
+			name:   "wifi first, then muted volume",
+			status: Status{Wifi: &WifiStatus{}, Volume: &VolumeStatus{Volume: 40, Muted: true}},
+			waybar: `{"text":"disconnected muted","alt":"wifi-off","tooltip":"wifi: disconnected\nvolume: 40% (muted)","class":["wifi-disconnected","volume-muted"],"percentage":0}`,
+			i3bar:  `[{"name":"wifi","full_text":"wifi: disconnected","_icon":"wifi-off","_class":"disconnected"},{"name":"volume","full_text":"volume: muted","_icon":"vol-muted","_class":"muted"}]`,
+		},
+		{
+			name:   "volume at 0",
+			status: Status{Volume: &VolumeStatus{Volume: 0}},
+			waybar: `{"text":"0%","alt":"vol-low","tooltip":"volume: 0%","class":["volume-unmuted"],"percentage":0}`,
+			i3bar:  `[{"name":"volume","full_text":"volume: 0%","_icon":"vol-low","_class":"unmuted"}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := waybarLine(tt.status); got != tt.waybar {
+				t.Errorf("waybar:\n got %s\nwant %s", got, tt.waybar)
+			}
+			if got := i3barLine(tt.status); got != tt.i3bar {
+				t.Errorf("i3bar:\n got %s\nwant %s", got, tt.i3bar)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// output is a compositor output (monitor), in layout coordinates
+type output struct {
+	Name    string
+	X       int
+	Y       int
+	Width   int
+	Height  int
+	Focused bool
+}
+
+// i3 IPC message type, as used by sway
+const swayGetOutputs = 3
+
+// Sends a message over the i3 IPC socket, and returns the reply payload
+func swayRequest(socketPath string, msgType uint32, payload string) ([]byte, error) {
+	conn, err := net.DialTimeout("unix", socketPath, time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+
+	header := make([]byte, 14)
+	copy(header, "i3-ipc")
+	binary.LittleEndian.PutUint32(header[6:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(header[10:], msgType)
+	_, err = conn.Write(append(header, payload...))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = io.ReadFull(conn, header)
+	if err != nil {
+		return nil, err
+	}
+	if string(header[:6]) != "i3-ipc" {
+		return nil, errors.New("invalid i3 IPC reply")
+	}
+	reply := make([]byte, binary.LittleEndian.Uint32(header[6:]))
+	_, err = io.ReadFull(conn, reply)
+	return reply, err
+}
+
+func swayOutputs(socketPath string) ([]output, error) {
+	reply, err := swayRequest(socketPath, swayGetOutputs, "")
+	if err != nil {
+		return nil, err
+	}
+	var outputs []struct {
+		Name    string `json:"name"`
+		Active  bool   `json:"active"`
+		Focused bool   `json:"focused"`
+		Rect    struct {
+			X      int `json:"x"`
+			Y      int `json:"y"`
+			Width  int `json:"width"`
+			Height int `json:"height"`
+		} `json:"rect"`
+	}
+	err = json.Unmarshal(reply, &outputs)
+	if err != nil {
+		return nil, err
+	}
+	var result []output
+	for _, o := range outputs {
+		if o.Active {
+			result = append(result, output{Name: o.Name, X: o.Rect.X, Y: o.Rect.Y, Width: o.Rect.Width,
+				Height: o.Rect.Height, Focused: o.Focused})
+		}
+	}
+	return result, nil
+}
+
+// Returns path to the Hyprland request socket, if Hyprland is running
+func hyprlandSocketPath() string {
+	signature := os.Getenv("HYPRLAND_INSTANCE_SIGNATURE")
+	if signature == "" {
+		return ""
+	}
+	// newer versions keep sockets in $XDG_RUNTIME_DIR/hypr, older ones in /tmp/hypr
+	path := filepath.Join(os.Getenv("XDG_RUNTIME_DIR"), "hypr", signature, ".socket.sock")
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+	return filepath.Join("/tmp/hypr", signature, ".socket.sock")
+}
+
+// Sends a command (like "j/monitors") to Hyprland, and returns the reply
+func hyprlandRequest(socketPath, command string) ([]byte, error) {
+	conn, err := net.DialTimeout("unix", socketPath, time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+	_, err = conn.Write([]byte(command))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(conn)
+}
+
+func hyprlandOutputs(socketPath string) ([]output, error) {
+	reply, err := hyprlandRequest(socketPath, "j/monitors")
+	if err != nil {
+		return nil, err
+	}
+	var monitors []struct {
+		Name    string  `json:"name"`
+		X       int     `json:"x"`
+		Y       int     `json:"y"`
+		Width   int     `json:"width"`
+		Height  int     `json:"height"`
+		Scale   float64 `json:"scale"`
+		Focused bool    `json:"focused"`
+	}
+	err = json.Unmarshal(reply, &monitors)
+	if err != nil {
+		return nil, err
+	}
+	var result []output
+	for _, m := range monitors {
+		// width and height are in pixels, we need logical size
+		if m.Scale <= 0 {
+			m.Scale = 1
+		}
+		result = append(result, output{Name: m.Name, X: m.X, Y: m.Y, Width: int(float64(m.Width) / m.Scale),
+			Height: int(float64(m.Height) / m.Scale), Focused: m.Focused})
+	}
+	return result, nil
+}
+
+func hyprlandCursor(socketPath string) (int, int, error) {
+	reply, err := hyprlandRequest(socketPath, "j/cursorpos")
+	if err != nil {
+		return 0, 0, err
+	}
+	var pos struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+	}
+	err = json.Unmarshal(reply, &pos)
+	return pos.X, pos.Y, err
+}
+
+func focusedOutput(outputs []output) *output {
+	for i := range outputs {
+		if outputs[i].Focused {
+			return &outputs[i]
+		}
+	}
+	return nil
+}
+
+// Asks the running compositor (sway or Hyprland) for the focused output, and the pointer position if available
+// (sway doesn't tell it). Returns nil output if unknown.
+func compositorPlacement() (out *output, x, y int, pointerKnown bool) {
+	if path := hyprlandSocketPath(); path != "" {
+		outputs, err := hyprlandOutputs(path)
+		if err != nil {
+			fmt.Println("Hyprland IPC:", err)
+			return nil, 0, 0, false
+		}
+		x, y, err := hyprlandCursor(path)
+		return focusedOutput(outputs), x, y, err == nil
+	}
+	if path := os.Getenv("SWAYSOCK"); path != "" {
+		outputs, err := swayOutputs(path)
+		if err != nil {
+			fmt.Println("sway IPC:", err)
+			return nil, 0, 0, false
+		}
+		return focusedOutput(outputs), 0, 0, false
+	}
+	return nil, 0, 0, false
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Replies recorded from sway 1.8 (`swaymsg -r -t get_outputs`, shortened) and Hyprland 0.34 (`hyprctl -j monitors`,
+// `hyprctl -j cursorpos`, shortened)
+const (
+	swayOutputsReply = `[
+  {"name": "eDP-1", "active": true, "focused": false, "scale": 1.5,
+   "rect": {"x": 0, "y": 0, "width": 1280, "height": 800}},
+  {"name": "HDMI-A-1", "active": true, "focused": true, "scale": 1.0,
+   "rect": {"x": 1280, "y": 0, "width": 1920, "height": 1080}},
+  {"name": "DP-2", "active": false, "focused": false, "rect": {"x": 0, "y": 0, "width": 0, "height": 0}}
+]`
+	hyprlandMonitorsReply = `[{
+    "id": 0, "name": "eDP-1", "description": "Chimei Innolux Corporation 0x14D3",
+    "width": 1920, "height": 1200, "refreshRate": 60.00000, "x": 0, "y": 0,
+    "activeWorkspace": {"id": 1, "name": "1"}, "scale": 1.50, "transform": 0, "focused": false
+},{
+    "id": 1, "name": "DP-1", "description": "Dell Inc. DELL U2719D",
+    "width": 2560, "height": 1440, "refreshRate": 59.95100, "x": 1280, "y": 0,
+    "activeWorkspace": {"id": 2, "name": "2"}, "scale": 1.00, "transform": 0, "focused": true
+}]`
+	hyprlandCursorReply = `{"x": 2000, "y": 300}`
+)
+
+// Serves a fake compositor IPC socket; `reply` answers each connection's request
+func fakeIpcSocket(t *testing.T, path string, reply func(conn net.Conn)) string {
+	t.Helper()
+	createDir(filepath.Dir(path))
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		listener.Close()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			reply(conn)
+			conn.Close()
+		}
+	}()
+	return path
+}
+
+// Fake sway: replies to get_outputs with the recorded reply, to anything else with an empty one
+func fakeSway(t *testing.T) string {
+	return fakeIpcSocket(t, filepath.Join(t.TempDir(), "sway-ipc.sock"), func(conn net.Conn) {
+		header := make([]byte, 14)
+		if _, err := io.ReadFull(conn, header); err != nil || string(header[:6]) != "i3-ipc" {
+			return
+		}
+		payload := make([]byte, binary.LittleEndian.Uint32(header[6:]))
+		io.ReadFull(conn, payload)
+		reply := "[]"
+		if binary.LittleEndian.Uint32(header[10:]) == swayGetOutputs {
+			reply = swayOutputsReply
+		}
+		binary.LittleEndian.PutUint32(header[6:], uint32(len(reply)))
+		conn.Write(append(header, reply...))
+	})
+}
+
+// Fake Hyprland: replies to j/monitors and j/cursorpos, then closes the connection, as Hyprland does
+func fakeHyprland(t *testing.T, path string) string {
+	return fakeIpcSocket(t, path, func(conn net.Conn) {
+		buf := make([]byte, 64)
+		n, _ := conn.Read(buf)
+		switch string(buf[:n]) {
+		case "j/monitors":
+			conn.Write([]byte(hyprlandMonitorsReply))
+		case "j/cursorpos":
+			conn.Write([]byte(hyprlandCursorReply))
+		default:
+			conn.Write([]byte("unknown request"))
+		}
+	})
+}
+
+func TestSwayOutputs(t *testing.T) {
+	outputs, err := swayOutputs(fakeSway(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []output{
+		{Name: "eDP-1", X: 0, Y: 0, Width: 1280, Height: 800},
+		{Name: "HDMI-A-1", X: 1280, Y: 0, Width: 1920, Height: 1080, Focused: true},
+	}
+	if !reflect.DeepEqual(outputs, want) {
+		t.Errorf("got %+v, want %+v", outputs, want)
+	}
+}
+
+func TestHyprlandOutputs(t *testing.T) {
+	path := fakeHyprland(t, filepath.Join(t.TempDir(), ".socket.sock"))
+	outputs, err := hyprlandOutputs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []output{
+		{Name: "eDP-1", X: 0, Y: 0, Width: 1280, Height: 800},
+		{Name: "DP-1", X: 1280, Y: 0, Width: 2560, Height: 1440, Focused: true},
+	}
+	if !reflect.DeepEqual(outputs, want) {
+		t.Errorf("got %+v, want %+v", outputs, want)
+	}
+
+	x, y, err := hyprlandCursor(path)
+	if err != nil || x != 2000 || y != 300 {
+		t.Errorf("cursor: got %d, %d, %v", x, y, err)
+	}
+}
+
+func TestCompositorPlacement(t *testing.T) {
+	tests := []struct {
+		name         string
+		setup        func(t *testing.T)
+		wantOutput   string
+		wantX, wantY int
+		wantPointer  bool
+	}{
+		{
+			name: "sway",
+			setup: func(t *testing.T) {
+				t.Setenv("HYPRLAND_INSTANCE_SIGNATURE", "")
+				t.Setenv("SWAYSOCK", fakeSway(t))
+			},
+			wantOutput: "HDMI-A-1",
+		},
+		{
+			name: "Hyprland",
+			setup: func(t *testing.T) {
+				runtimeDir := t.TempDir()
+				fakeHyprland(t, filepath.Join(runtimeDir, "hypr", "v0.34.0_1700000000", ".socket.sock"))
+				t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+				t.Setenv("HYPRLAND_INSTANCE_SIGNATURE", "v0.34.0_1700000000")
+				t.Setenv("SWAYSOCK", "")
+			},
+			wantOutput:  "DP-1",
+			wantX:       2000,
+			wantY:       300,
+			wantPointer: true,
+		},
+		{
+			name: "neither",
+			setup: func(t *testing.T) {
+				t.Setenv("HYPRLAND_INSTANCE_SIGNATURE", "")
+				t.Setenv("SWAYSOCK", "")
+			},
+		},
+		{
+			name: "sway socket gone",
+			setup: func(t *testing.T) {
+				t.Setenv("HYPRLAND_INSTANCE_SIGNATURE", "")
+				t.Setenv("SWAYSOCK", filepath.Join(t.TempDir(), "missing.sock"))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup(t)
+			out, x, y, pointerKnown := compositorPlacement()
+			name := ""
+			if out != nil {
+				name = out.Name
+			}
+			if name != tt.wantOutput || x != tt.wantX || y != tt.wantY || pointerKnown != tt.wantPointer {
+				t.Errorf("got %q at %d, %d (pointer known: %t)", name, x, y, pointerKnown)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+var (
+	confirmBox     *gtk.Box // shown at the bottom of the window while waiting for confirmation
+	confirmLabel   *gtk.Label
+	confirmTimer   glib.SourceHandle
+	confirmAction  string
+	confirmSeconds int
+)
+
+// Returns the confirmation message of a row or button, or "" if it doesn't need confirmation
+func confirmMessage(confirm bool, message, name string) string {
+	if !confirm {
+		return ""
+	}
+	if message != "" {
+		return message
+	}
+	return fmt.Sprintf("Running '%s'", name)
+}
+
+// Runs the action, or asks first if given a confirmation message
+func confirmOrRun(message, action string) {
+	if message != "" {
+		askConfirmation(message, action)
+	} else {
+		runAction(action)
+	}
+}
+
+// Builds the inline confirmation bar, hidden until needed
+func setupConfirmBox() *gtk.Box {
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	box.SetNoShowAll(true)
+	box.SetProperty("name", "confirm")
+
+	confirmLabel, _ = gtk.LabelNew("")
+	box.PackStart(confirmLabel, true, true, 4)
+	confirmLabel.Show()
+
+	btnCancel, _ := gtk.ButtonNewWithLabel("Cancel")
+	btnCancel.Connect("clicked", cancelConfirmation)
+	box.PackEnd(btnCancel, false, false, 4)
+	btnCancel.Show()
+
+	btnNow, _ := gtk.ButtonNewWithLabel("OK")
+	btnNow.Connect("clicked", func() {
+		action := confirmAction
+		cancelConfirmation()
+		runAction(action)
+	})
+	box.PackEnd(btnNow, false, false, 4)
+	btnNow.Show()
+
+	return box
+}
+
+// Asks to confirm the action inline. With `confirm_seconds` above 0, the action runs when the countdown ends,
+// unless cancelled; otherwise it waits for OK.
+func askConfirmation(message, action string) {
+	if confirmBox == nil || action == "" {
+		return
+	}
+	cancelConfirmation()
+	confirmAction = action
+	confirmSeconds = settings.Preferences.ConfirmSeconds
+	if confirmSeconds <= 0 {
+		confirmLabel.SetText(fmt.Sprintf("%s?", message))
+		confirmBox.Show()
+		return
+	}
+
+	confirmLabel.SetText(fmt.Sprintf("%s in %ds", message, confirmSeconds))
+	confirmBox.Show()
+	confirmTimer = glib.TimeoutAdd(uint(1000), func() bool {
+		confirmSeconds--
+		if confirmSeconds > 0 {
+			confirmLabel.SetText(fmt.Sprintf("%s in %ds", message, confirmSeconds))
+			return true
+		}
+		confirmTimer = 0
+		action := confirmAction
+		cancelConfirmation()
+		runAction(action)
+		return false
+	})
+}
+
+// Stops the countdown, if any, and hides the confirmation bar
+func cancelConfirmation() {
+	if confirmTimer != 0 {
+		glib.SourceRemove(confirmTimer)
+		confirmTimer = 0
+	}
+	confirmAction = ""
+	if confirmBox != nil {
+		confirmBox.Hide()
+	}
+}
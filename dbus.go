@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+// Session bus name, object path and interface of the nwgocc service
+const (
+	dbusName      = "com.github.nwgpiotr.nwgocc"
+	dbusPath      = "/com/github/nwgpiotr/nwgocc"
+	dbusInterface = "com.github.nwgpiotr.nwgocc"
+)
+
+// dbusService methods get exported on the bus; they do the same as `nwgocc msg` commands
+type dbusService struct{}
+
+func (dbusService) execute(request ipcRequest) *dbus.Error {
+	response := executeInMainLoop(request)
+	if !response.Success {
+		return dbus.MakeFailedError(errors.New(response.Error))
+	}
+	return nil
+}
+
+func (s dbusService) Show() *dbus.Error {
+	return s.execute(ipcRequest{Command: "show"})
+}
+
+func (s dbusService) Hide() *dbus.Error {
+	return s.execute(ipcRequest{Command: "hide"})
+}
+
+func (s dbusService) Toggle() *dbus.Error {
+	return s.execute(ipcRequest{Command: "toggle"})
+}
+
+func (s dbusService) Reload() *dbus.Error {
+	return s.execute(ipcRequest{Command: "reload"})
+}
+
+func (s dbusService) SetVolume(value string) *dbus.Error {
+	return s.execute(ipcRequest{Command: "volume", Value: value})
+}
+
+func (s dbusService) SetBrightness(value string) *dbus.Error {
+	return s.execute(ipcRequest{Command: "brightness", Value: value})
+}
+
+func (s dbusService) Run(name string) *dbus.Error {
+	return s.execute(ipcRequest{Command: "run", Name: name})
+}
+
+// Read-only properties, with their initial values; PropertiesChanged gets emitted whenever a value changes
+func dbusProperties() map[string]*prop.Prop {
+	properties := map[string]interface{}{
+		"BatteryPercentage": int32(0),
+		"BatteryStatus":     "",
+		"AcPower":           false,
+		"Volume":            int32(0),
+		"Muted":             false,
+		"Brightness":        int32(0),
+		"WifiConnected":     false,
+		"WifiSsid":          "",
+		"BluetoothPowered":  false,
+		"Interface":         "",
+		"InterfaceUp":       false,
+		"InterfaceAddress":  "",
+		"DefaultInterface":  "",
+	}
+	m := make(map[string]*prop.Prop)
+	for name, value := range properties {
+		m[name] = &prop.Prop{Value: value, Writable: false, Emit: prop.EmitTrue}
+	}
+	return m
+}
+
+// Sets the property value, if changed
+func setDbusProperty(props *prop.Properties, name string, value interface{}) {
+	if props.GetMust(dbusInterface, name) != value {
+		props.SetMust(dbusInterface, name, value)
+	}
+}
+
+func updateDbusProperties(props *prop.Properties, s Status) {
+	if s.Battery != nil {
+		setDbusProperty(props, "BatteryPercentage", int32(s.Battery.Percentage))
+		setDbusProperty(props, "BatteryStatus", s.Battery.Status)
+		setDbusProperty(props, "AcPower", s.Battery.Ac)
+	}
+	if s.Volume != nil {
+		setDbusProperty(props, "Volume", int32(s.Volume.Volume))
+		setDbusProperty(props, "Muted", s.Volume.Muted)
+	}
+	if s.Brightness != nil {
+		setDbusProperty(props, "Brightness", int32(s.Brightness.Brightness))
+	}
+	if s.Wifi != nil {
+		setDbusProperty(props, "WifiConnected", s.Wifi.Connected)
+		setDbusProperty(props, "WifiSsid", s.Wifi.Ssid)
+	}
+	if s.Bluetooth != nil {
+		setDbusProperty(props, "BluetoothPowered", s.Bluetooth.Powered)
+	}
+	if s.Net != nil {
+		setDbusProperty(props, "Interface", s.Net.Interface)
+		setDbusProperty(props, "InterfaceUp", s.Net.Up)
+		setDbusProperty(props, "InterfaceAddress", s.Net.Address)
+		setDbusProperty(props, "DefaultInterface", s.Net.DefaultInterface)
+	}
+}
+
+// Owns the nwgocc name on the session bus (the one from DBUS_SESSION_BUS_ADDRESS), exports methods and properties,
+// and registers a status watcher to keep properties up to date.
+func startDbusService() error {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return err
+	}
+	reply, err := conn.RequestName(dbusName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		conn.Close()
+		return fmt.Errorf("D-Bus name '%s' already taken", dbusName)
+	}
+
+	service := dbusService{}
+	err = conn.Export(service, dbusPath, dbusInterface)
+	if err != nil {
+		return err
+	}
+	props, err := prop.Export(conn, dbusPath, prop.Map{dbusInterface: dbusProperties()})
+	if err != nil {
+		return err
+	}
+	node := &introspect.Node{
+		Name: dbusPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       dbusInterface,
+				Methods:    introspect.Methods(service),
+				Properties: props.Introspection(dbusInterface),
+			},
+		},
+	}
+	err = conn.Export(introspect.NewIntrospectable(node), dbusPath, "org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		return err
+	}
+
+	statusWatchers = append(statusWatchers, func(previous, current Status) {
+		updateDbusProperties(props, current)
+	})
+	return nil
+}
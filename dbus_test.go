@@ -0,0 +1,134 @@
+package main
+
+import (
+	"os/exec"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Starts a private dbus-daemon for the test, stopped when it ends; returns its address
+func privateBus(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon not found")
+	}
+	out, err := exec.Command("dbus-daemon", "--session", "--print-address", "--print-pid", "--fork",
+		"--nopidfile").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) != 2 {
+		t.Fatalf("unexpected dbus-daemon output: %q", out)
+	}
+	pid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		syscall.Kill(pid, syscall.SIGTERM)
+	})
+	return fields[0]
+}
+
+func TestDbusService(t *testing.T) {
+	address := privateBus(t)
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", address)
+
+	watchers := len(statusWatchers)
+	if err := startDbusService(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		statusWatchers = statusWatchers[:watchers]
+	}()
+	if len(statusWatchers) != watchers+1 {
+		t.Fatal("no status watcher registered")
+	}
+	watcher := statusWatchers[watchers]
+
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	err = conn.AddMatchSignal(dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+		dbus.WithMatchMember("PropertiesChanged"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signals := make(chan *dbus.Signal, 10)
+	conn.Signal(signals)
+	obj := conn.Object(dbusName, dbusPath)
+
+	var xml string
+	err = obj.Call("org.freedesktop.DBus.Introspectable.Introspect", 0).Store(&xml)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Toggle", "SetVolume", "BatteryPercentage", "DefaultInterface"} {
+		if !strings.Contains(xml, name) {
+			t.Errorf("'%s' not found in introspection data", name)
+		}
+	}
+
+	// names of properties in PropertiesChanged signals received until none come for a while
+	changed := func() []string {
+		var names []string
+		for {
+			select {
+			case signal := <-signals:
+				if len(signal.Body) > 1 {
+					for name := range signal.Body[1].(map[string]dbus.Variant) {
+						names = append(names, name)
+					}
+				}
+			case <-time.After(200 * time.Millisecond):
+				sort.Strings(names)
+				return names
+			}
+		}
+	}
+
+	tests := []struct {
+		name        string
+		status      Status
+		property    string
+		want        interface{}
+		wantChanged []string
+	}{
+		{"volume changed", Status{Volume: &VolumeStatus{Volume: 42, Muted: true}}, "Volume", int32(42),
+			[]string{"Muted", "Volume"}},
+		{"volume unchanged", Status{Volume: &VolumeStatus{Volume: 42, Muted: true}}, "Muted", true, nil},
+		{"battery", Status{Battery: &BatteryStatus{Percentage: 15, Status: "Discharging"}}, "BatteryStatus",
+			"Discharging", []string{"BatteryPercentage", "BatteryStatus"}},
+		{"other sections keep values", Status{Wifi: &WifiStatus{Connected: true, Ssid: "home"}}, "Volume",
+			int32(42), []string{"WifiConnected", "WifiSsid"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			watcher(Status{}, tt.status)
+			value, err := obj.GetProperty(dbusInterface + "." + tt.property)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value.Value() != tt.want {
+				t.Errorf("%s: got %v, want %v", tt.property, value.Value(), tt.want)
+			}
+			if got := changed(); !reflect.DeepEqual(got, tt.wantChanged) {
+				t.Errorf("changed: got %q, want %q", got, tt.wantChanged)
+			}
+		})
+	}
+
+	if err := startDbusService(); err == nil {
+		t.Error("name owned twice")
+	}
+}
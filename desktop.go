@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// desktopEntry holds fields of an XDG .desktop file we care of
+type desktopEntry struct {
+	ID       string // e.g. "org.gnome.Nautilus.desktop"
+	Name     string
+	Exec     string
+	Icon     string
+	Comment  string
+	Terminal bool
+	NoShow   bool // NoDisplay or Hidden
+}
+
+// $XDG_DATA_HOME/applications first, then $XDG_DATA_DIRS/applications
+func applicationDirs() []string {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, _ := os.UserHomeDir()
+		dataHome = filepath.Join(home, ".local/share")
+	}
+	dirs := []string{filepath.Join(dataHome, "applications")}
+
+	dataDirs := os.Getenv("XDG_DATA_DIRS")
+	if dataDirs == "" {
+		dataDirs = "/usr/local/share:/usr/share"
+	}
+	for _, d := range strings.Split(dataDirs, ":") {
+		if d != "" {
+			dirs = append(dirs, filepath.Join(d, "applications"))
+		}
+	}
+	return dirs
+}
+
+// Parses the [Desktop Entry] group of a .desktop file; localized keys are skipped
+func parseDesktopFile(path string) (desktopEntry, error) {
+	var entry desktopEntry
+	file, err := os.Open(path)
+	if err != nil {
+		return entry, err
+	}
+	defer file.Close()
+
+	inGroup := false
+	entryType := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			inGroup = line == "[Desktop Entry]"
+			continue
+		}
+		if !inGroup {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key, value := strings.TrimSpace(parts[0]), unescapeValue(strings.TrimSpace(parts[1]))
+		switch key {
+		case "Type":
+			entryType = value
+		case "Name":
+			entry.Name = value
+		case "Exec":
+			entry.Exec = value
+		case "Icon":
+			entry.Icon = value
+		case "Comment":
+			entry.Comment = value
+		case "Terminal":
+			entry.Terminal = value == "true"
+		case "NoDisplay", "Hidden":
+			if value == "true" {
+				entry.NoShow = true
+			}
+		}
+	}
+	if entryType != "Application" {
+		entry.NoShow = true
+	}
+	return entry, scanner.Err()
+}
+
+// Returns applications to be shown; a desktop ID found in more than one directory is taken from the first one,
+// as the XDG spec says
+func listDesktopEntries() []desktopEntry {
+	var entries []desktopEntry
+	seen := make(map[string]bool)
+	for _, dir := range applicationDirs() {
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || !strings.HasSuffix(path, ".desktop") {
+				return nil
+			}
+			rel, _ := filepath.Rel(dir, path)
+			id := strings.ReplaceAll(rel, string(filepath.Separator), "-")
+			if seen[id] {
+				return nil
+			}
+			seen[id] = true
+
+			entry, err := parseDesktopFile(path)
+			if err != nil || entry.NoShow || entry.Name == "" || desktopCommand(entry.Exec) == "" {
+				return nil
+			}
+			entry.ID = id
+			entries = append(entries, entry)
+			return nil
+		})
+	}
+	return entries
+}
+
+// Replaces escape sequences of a string value: `\s`, `\n`, `\t`, `\r` and `\\`
+func unescapeValue(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i == len(value)-1 {
+			b.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case 's':
+			b.WriteByte(' ')
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case '\\':
+			b.WriteByte('\\')
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String()
+}
+
+// Splits the (unescaped) Exec key into arguments, as the XDG spec says: arguments containing spaces are quoted
+// with double quotes, inside which a double quote, backtick, dollar sign or backslash is escaped with a backslash.
+// `%%` stands for a literal `%`. An argument with any other field code in it (%f, %U, %i, %c etc.) is dropped
+// as a whole, e.g. `--file=%f`: we pass no files, and don't expand the icon and name ones.
+func parseExec(exec string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg, quoted, codeArg := false, false, false
+	endArg := func() {
+		if inArg && !codeArg {
+			args = append(args, arg.String())
+		}
+		arg.Reset()
+		inArg, codeArg = false, false
+	}
+	for i := 0; i < len(exec); i++ {
+		c := exec[i]
+		switch {
+		case quoted && c == '"':
+			quoted = false
+		case quoted && c == '\\':
+			if i == len(exec)-1 {
+				return nil, fmt.Errorf("unfinished escape in '%s'", exec)
+			}
+			i++
+			arg.WriteByte(exec[i])
+		case !quoted && c == '"':
+			quoted, inArg = true, true
+		case !quoted && (c == ' ' || c == '\t'):
+			endArg()
+		case c == '%' && i < len(exec)-1:
+			i++
+			if exec[i] == '%' {
+				arg.WriteByte('%')
+				inArg = true
+			} else {
+				codeArg = true
+			}
+		default:
+			arg.WriteByte(c)
+			inArg = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote in '%s'", exec)
+	}
+	endArg()
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty command in '%s'", exec)
+	}
+	return args, nil
+}
+
+// Joins arguments into a `sh -c` command line, single-quoting these that need it
+func shellQuote(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		safe := arg != ""
+		for _, r := range arg {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("@%_+=:,./-", r)) {
+				safe = false
+				break
+			}
+		}
+		if safe {
+			quoted[i] = arg
+		} else {
+			quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// Returns the command to run for the Exec key of a .desktop file, or "" if it can't be parsed
+func desktopCommand(exec string) string {
+	args, err := parseExec(exec)
+	if err != nil {
+		fmt.Println(err)
+		return ""
+	}
+	return shellQuote(args)
+}
+
+// Updates commands and icons of custom rows and buttons created from .desktop files, in case the files changed;
+// labels are left as the user set them
+func refreshDesktopReferences(c *Configuration) {
+	var entries map[string]desktopEntry
+	lookup := func(id string) (desktopEntry, bool) {
+		if entries == nil {
+			entries = make(map[string]desktopEntry)
+			for _, entry := range listDesktopEntries() {
+				entries[entry.ID] = entry
+			}
+		}
+		entry, ok := entries[id]
+		return entry, ok
+	}
+
+	for i, row := range c.CustomRows {
+		if row.DesktopID == "" {
+			continue
+		}
+		if entry, ok := lookup(row.DesktopID); ok {
+			if command := desktopCommand(entry.Exec); command != "" {
+				c.CustomRows[i].Command = command
+			}
+			c.CustomRows[i].Icon = entry.Icon
+		}
+	}
+	for i, btn := range c.Buttons {
+		if btn.DesktopID == "" {
+			continue
+		}
+		if entry, ok := lookup(btn.DesktopID); ok {
+			if command := desktopCommand(entry.Exec); command != "" {
+				c.Buttons[i].Command = command
+			}
+			c.Buttons[i].Icon = entry.Icon
+		}
+	}
+}
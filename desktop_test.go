@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseExec(t *testing.T) {
+	tests := []struct {
+		exec    string
+		want    []string
+		wantErr bool
+	}{
+		{"firefox %u", []string{"firefox"}, false},
+		{"nautilus --new-window %U", []string{"nautilus", "--new-window"}, false},
+		{`"/opt/My App/app" %U`, []string{"/opt/My App/app"}, false},
+		{`sh -c "a b"`, []string{"sh", "-c", "a b"}, false},
+		{`sh -c "echo \"hi\" \$HOME \\ \` + "`" + `"`, []string{"sh", "-c", "echo \"hi\" $HOME \\ `"}, false},
+		{"app --file=%f --verbose", []string{"app", "--verbose"}, false},
+		{"app --icon=%i --name=%c --verbose", []string{"app", "--verbose"}, false},
+		{"app %i %c %k", []string{"app"}, false},
+		{"printf 100%%", []string{"printf", "100%"}, false},
+		{`app ""`, []string{"app", ""}, false},
+		{"  app\t --x  ", []string{"app", "--x"}, false},
+		{`app "unterminated`, nil, true},
+		{`"app\`, nil, true},
+		{"%U", nil, true},
+		{"", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseExec(tt.exec)
+		if !reflect.DeepEqual(got, tt.want) || (err != nil) != tt.wantErr {
+			t.Errorf("parseExec(%q) = %q, %v; want %q", tt.exec, got, err, tt.want)
+		}
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"firefox", "--new-window"}, "firefox --new-window"},
+		{[]string{"/opt/My App/app"}, "'/opt/My App/app'"},
+		{[]string{"sh", "-c", "a b"}, "sh -c 'a b'"},
+		{[]string{"echo", "it's $HOME"}, `echo 'it'\''s $HOME'`},
+		{[]string{"app", ""}, "app ''"},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.args); got != tt.want {
+			t.Errorf("shellQuote(%q) = %s; want %s", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestParseDesktopFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    desktopEntry
+	}{
+		{
+			name: "application",
+			content: "[Desktop Entry]\nType=Application\nName=Files\nName[de]=Dateien\nExec=nautilus %U\n" +
+				"Icon=org.gnome.Nautilus\nComment=Access files\nTerminal=false\n",
+			want: desktopEntry{Name: "Files", Exec: "nautilus %U", Icon: "org.gnome.Nautilus", Comment: "Access files"},
+		},
+		{
+			name:    "escapes unescaped",
+			content: "[Desktop Entry]\nType=Application\nName=My\\sApp\nExec=\"/opt/My App/app\" --dir \\\\tmp\n",
+			want:    desktopEntry{Name: "My App", Exec: `"/opt/My App/app" --dir \tmp`},
+		},
+		{
+			name: "other groups ignored",
+			content: "# comment\n[Desktop Entry]\nType=Application\nName=App\nExec=app\n\n" +
+				"[Desktop Action new]\nName=New Window\nExec=app --new\n",
+			want: desktopEntry{Name: "App", Exec: "app"},
+		},
+		{
+			name:    "terminal",
+			content: "[Desktop Entry]\nType=Application\nName=Top\nExec=htop\nTerminal=true\n",
+			want:    desktopEntry{Name: "Top", Exec: "htop", Terminal: true},
+		},
+		{
+			name:    "hidden",
+			content: "[Desktop Entry]\nType=Application\nName=App\nExec=app\nNoDisplay=true\n",
+			want:    desktopEntry{Name: "App", Exec: "app", NoShow: true},
+		},
+		{
+			name:    "not an application",
+			content: "[Desktop Entry]\nType=Link\nName=Site\nURL=https://example.com\n",
+			want:    desktopEntry{Name: "Site", NoShow: true},
+		},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "test.desktop")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := parseDesktopFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+// statusEvent is a state transition found between two status polls, e.g. "ac-connected", or "wifi-connected" with
+// the SSID as the value
+type statusEvent struct {
+	Name  string
+	Value string
+}
+
+// Returns state transitions between the previous and the current status. Sections missing in any of them
+// (e.g. on the first poll) are skipped.
+func statusEvents(previous, current Status) []statusEvent {
+	var events []statusEvent
+	add := func(name, value string) {
+		events = append(events, statusEvent{Name: name, Value: value})
+	}
+
+	if previous.Battery != nil && current.Battery != nil && previous.Battery.Ac != current.Battery.Ac {
+		if current.Battery.Ac {
+			add("ac-connected", "")
+		} else {
+			add("ac-disconnected", "")
+		}
+	}
+
+	if previous.Wifi != nil && current.Wifi != nil && previous.Wifi.Ssid != current.Wifi.Ssid {
+		if previous.Wifi.Connected {
+			add("wifi-disconnected", previous.Wifi.Ssid)
+		}
+		if current.Wifi.Connected {
+			add("wifi-connected", current.Wifi.Ssid)
+		}
+		add("ssid-changed", current.Wifi.Ssid)
+	}
+
+	if previous.Net != nil && current.Net != nil && current.Net.Interface != "" &&
+		previous.Net.Interface == current.Net.Interface && previous.Net.Up != current.Net.Up {
+		if current.Net.Up {
+			add("interface-up", current.Net.Interface)
+		} else {
+			add("interface-down", current.Net.Interface)
+		}
+	}
+
+	if previous.Volume != nil && current.Volume != nil && previous.Volume.Muted != current.Volume.Muted {
+		if current.Volume.Muted {
+			add("volume-muted", "")
+		} else {
+			add("volume-unmuted", "")
+		}
+	}
+
+	if previous.Bluetooth != nil && current.Bluetooth != nil {
+		if previous.Bluetooth.Powered != current.Bluetooth.Powered {
+			if current.Bluetooth.Powered {
+				add("bt-on", current.Bluetooth.Name)
+			} else {
+				add("bt-off", previous.Bluetooth.Name)
+			}
+		}
+		for _, device := range current.Bluetooth.Devices {
+			if !contains(previous.Bluetooth.Devices, device) {
+				add("bt-device-connected", device)
+			}
+		}
+		for _, device := range previous.Bluetooth.Devices {
+			if !contains(current.Bluetooth.Devices, device) {
+				add("bt-device-disconnected", device)
+			}
+		}
+	}
+	return events
+}
+
+// Checks if the battery level has just dropped to or below the threshold, while not on AC power
+func batteryDropped(previous, current Status, threshold int) bool {
+	if previous.Battery == nil || current.Battery == nil || current.Battery.Ac || current.Battery.Status == "" {
+		return false
+	}
+	if current.Battery.Percentage > threshold {
+		return false
+	}
+	// also when unplugged from the charger with the level already low
+	return previous.Battery.Percentage > threshold || previous.Battery.Ac
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -4,6 +4,7 @@ go 1.18
 
 require (
 	github.com/allan-simon/go-singleinstance v0.0.0-20210120080615-d0997106ab37
+	github.com/godbus/dbus/v5 v5.1.0
 	github.com/gotk3/gotk3 v0.6.1
 	github.com/itchyny/volume-go v0.2.1
 )
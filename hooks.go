@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Hook runs the command on the event. The value is the threshold for "battery-below", and an optional pattern
+// (as in rules: glob, "!" negates) matched against the SSID, interface or device name for other events.
+type Hook struct {
+	Event   string `json:"event"`
+	Value   string `json:"value,omitempty"`
+	Command string `json:"cmd"`
+}
+
+// Events hooks may be defined for, besides "battery-below"
+var hookEvents = []string{"ac-connected", "ac-disconnected", "wifi-connected", "wifi-disconnected", "ssid-changed",
+	"interface-up", "interface-down", "bt-on", "bt-off", "bt-device-connected", "bt-device-disconnected",
+	"volume-muted", "volume-unmuted"}
+
+// Runs commands of hooks matching state transitions. The event name and value are passed to the command
+// in the NWGOCC_EVENT and NWGOCC_VALUE environment variables.
+func runHooks(previous, current Status) {
+	if len(settings.Hooks) == 0 {
+		return
+	}
+	events := statusEvents(previous, current)
+	for _, hook := range settings.Hooks {
+		if hook.Command == "" {
+			continue
+		}
+		if hook.Event == "battery-below" {
+			threshold, err := strconv.Atoi(hook.Value)
+			if err == nil && batteryDropped(previous, current, threshold) {
+				runHook(hook, statusEvent{Name: hook.Event, Value: strconv.Itoa(current.Battery.Percentage)})
+			}
+			continue
+		}
+		for _, event := range events {
+			if event.Name == hook.Event && (hook.Value == "" || matchValue(hook.Value, event.Value)) {
+				runHook(hook, event)
+			}
+		}
+	}
+}
+
+func runHook(hook Hook, event statusEvent) {
+	fmt.Printf("Hook on '%s': %s\n", event.Name, hook.Command)
+	runShellCommand(hook.Command, fmt.Sprintf("NWGOCC_EVENT=%s", event.Name), fmt.Sprintf("NWGOCC_VALUE=%s", event.Value))
+}
+
+// Checks hooks defined in preferences, and registers the hooks status watcher; it runs the hooks defined at the time,
+// as preferences may get reloaded
+func startHooks() {
+	checkHooks()
+	statusWatchers = append(statusWatchers, runHooks)
+}
+
+// Warns about hooks on unknown events
+func checkHooks() {
+	for _, hook := range settings.Hooks {
+		if hook.Event != "battery-below" && !contains(hookEvents, hook.Event) {
+			fmt.Printf("Unknown hook event '%s'\n", hook.Event)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStatusEvents(t *testing.T) {
+	tests := []struct {
+		name              string
+		previous, current Status
+		want              []statusEvent
+	}{
+		{
+			name:     "first poll",
+			previous: Status{},
+			current:  Status{Battery: &BatteryStatus{Ac: true}, Wifi: &WifiStatus{Connected: true, Ssid: "home"}},
+			want:     nil,
+		},
+		{
+			name:     "charger unplugged",
+			previous: Status{Battery: &BatteryStatus{Percentage: 50, Ac: true}},
+			current:  Status{Battery: &BatteryStatus{Percentage: 50}},
+			want:     []statusEvent{{Name: "ac-disconnected"}},
+		},
+		{
+			name:     "network switched",
+			previous: Status{Wifi: &WifiStatus{Connected: true, Ssid: "home"}},
+			current:  Status{Wifi: &WifiStatus{Connected: true, Ssid: "office"}},
+			want: []statusEvent{{Name: "wifi-disconnected", Value: "home"}, {Name: "wifi-connected", Value: "office"},
+				{Name: "ssid-changed", Value: "office"}},
+		},
+		{
+			name:     "interface down",
+			previous: Status{Net: &NetStatus{Interface: "wg0", Up: true}},
+			current:  Status{Net: &NetStatus{Interface: "wg0"}},
+			want:     []statusEvent{{Name: "interface-down", Value: "wg0"}},
+		},
+		{
+			name:     "muted",
+			previous: Status{Volume: &VolumeStatus{Volume: 30}},
+			current:  Status{Volume: &VolumeStatus{Volume: 30, Muted: true}},
+			want:     []statusEvent{{Name: "volume-muted"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := statusEvents(tt.previous, tt.current); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunHooks(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "hook output")
+	hooks := settings.Hooks
+	defer func() {
+		settings.Hooks = hooks
+	}()
+	settings.Hooks = []Hook{
+		{Event: "wifi-connected", Value: "office*", Command: `echo "$NWGOCC_EVENT $NWGOCC_VALUE" >> '` + output + `'`},
+		{Event: "wifi-connected", Value: "!office*", Command: `echo unexpected >> '` + output + `'`},
+	}
+
+	runHooks(Status{Wifi: &WifiStatus{}}, Status{Wifi: &WifiStatus{Connected: true, Ssid: "office 5G"}})
+
+	want := "wifi-connected office 5G\n"
+	got := ""
+	for i := 0; i < 50 && got != want; i++ {
+		time.Sleep(20 * time.Millisecond)
+		content, _ := os.ReadFile(output)
+		got = string(content)
+	}
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
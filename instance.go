@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/allan-simon/go-singleinstance"
+)
+
+// Returns path of a per-user runtime file, e.g. `$XDG_RUNTIME_DIR/nwgocc.lock`, or `/tmp/nwgocc-1000.lock` if
+// XDG_RUNTIME_DIR not set
+func runtimePath(ext string) string {
+	if os.Getenv("XDG_RUNTIME_DIR") != "" {
+		return filepath.Join(os.Getenv("XDG_RUNTIME_DIR"), fmt.Sprintf("nwgocc.%s", ext))
+	}
+	return filepath.Join(tempDir(), fmt.Sprintf("nwgocc-%d.%s", os.Getuid(), ext))
+}
+
+func lockFilePath() string {
+	return runtimePath("lock")
+}
+
+// Checks if the process of given PID is nwgocc: by /proc/<pid>/exe, or by cmdline if exe not readable
+func isNwgocc(pid int) bool {
+	if pid <= 0 || pid == os.Getpid() {
+		return false
+	}
+	name := "nwgocc"
+	if self, err := os.Executable(); err == nil {
+		name = filepath.Base(self)
+	}
+	exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+	if err == nil {
+		exe = strings.TrimSuffix(exe, " (deleted)")
+		return filepath.Base(exe) == name
+	}
+	cmdline, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil || len(cmdline) == 0 {
+		return false
+	}
+	argv0 := strings.Split(string(cmdline), "\x00")[0]
+	return filepath.Base(argv0) == name
+}
+
+// Returns PID from the lock file, if it belongs to a running nwgocc instance; 0 otherwise
+func instancePid() int {
+	text, err := readTextFile(lockFilePath())
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(text))
+	if err != nil || !isNwgocc(pid) {
+		return 0
+	}
+	return pid
+}
+
+// Creates and locks the lock file. A file left after a crash is not locked anymore, as the lock goes away
+// with its holder, so it just gets taken over; a locked one is never removed, whatever holds it.
+func createLock() (*os.File, error) {
+	path := lockFilePath()
+	file, err := singleinstance.CreateLockFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// The previous owner may have removed the file after we opened it: we'd hold a lock on nothing
+	info, err1 := file.Stat()
+	pathInfo, err2 := os.Stat(path)
+	if err1 != nil || err2 != nil || !os.SameFile(info, pathInfo) {
+		file.Close()
+		return nil, errors.New("lock file replaced")
+	}
+	return file, nil
+}
+
+// Removes the lock file, while still holding the lock
+func releaseLock(file *os.File) {
+	os.Remove(lockFilePath())
+	file.Close()
+}
+
+// Takes the single instance lock. If another instance is running: with `-replace` terminates it and takes over,
+// otherwise (`-toggle`, default) toggles the window of the instance running in daemon mode, or terminates
+// the running instance, and exits.
+func acquireInstanceLock() *os.File {
+	file, err := createLock()
+	if err == nil {
+		return file
+	}
+	pid := instancePid()
+
+	if *replaceInstance && !*toggleInstance {
+		if pid > 0 {
+			fmt.Printf("Running instance found, replacing PID %d\n", pid)
+			syscall.Kill(pid, syscall.SIGTERM)
+		}
+		for i := 0; i < 50; i++ {
+			time.Sleep(100 * time.Millisecond)
+			file, err = createLock()
+			if err == nil {
+				return file
+			}
+		}
+		fmt.Println("Couldn't replace the running instance:", err)
+		os.Exit(1)
+	}
+
+	response, err := sendIpcRequest(ipcRequest{Command: "toggle"})
+	if err == nil && response.Success {
+		fmt.Println("Running instance found, window toggled")
+		os.Exit(0)
+	}
+	if pid > 0 {
+		fmt.Println("Running instance found, sending SIGTERM and exiting...")
+		syscall.Kill(pid, syscall.SIGTERM)
+	}
+	os.Exit(0)
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestCreateLock(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	// a file left after a crash: nothing holds its lock
+	if err := os.WriteFile(lockFilePath(), []byte("999999"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := createLock()
+	if err != nil {
+		t.Fatalf("stale lock file not taken over: %s", err)
+	}
+
+	// locked: another instance must neither get the lock nor remove the file
+	if _, err := createLock(); err == nil {
+		t.Fatal("lock acquired twice")
+	}
+	info, err := os.Stat(lockFilePath())
+	if err != nil {
+		t.Fatalf("lock file removed: %s", err)
+	}
+	held, _ := file.Stat()
+	if !os.SameFile(info, held) {
+		t.Error("lock file replaced")
+	}
+
+	releaseLock(file)
+	if _, err := os.Stat(lockFilePath()); !os.IsNotExist(err) {
+		t.Errorf("lock file left after release: %v", err)
+	}
+}
@@ -7,7 +7,9 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
 )
 
@@ -30,9 +32,69 @@ func configDir() string {
 }
 
 func dataDir() string {
+	if os.Getenv("XDG_DATA_HOME") != "" {
+		return (fmt.Sprintf("%s/nwgocc", os.Getenv("XDG_DATA_HOME")))
+	}
 	return (fmt.Sprintf("%s/.local/share/nwgocc", os.Getenv("HOME")))
 }
 
+func stateDir() string {
+	if os.Getenv("XDG_STATE_HOME") != "" {
+		return (fmt.Sprintf("%s/nwgocc", os.Getenv("XDG_STATE_HOME")))
+	}
+	return (fmt.Sprintf("%s/.local/state/nwgocc", os.Getenv("HOME")))
+}
+
+// Returns system-wide config dirs from $XDG_CONFIG_DIRS (default: /etc/xdg), most important first
+func systemConfigDirs() []string {
+	dirs := os.Getenv("XDG_CONFIG_DIRS")
+	if dirs == "" {
+		dirs = "/etc/xdg"
+	}
+	var output []string
+	for _, dir := range strings.Split(dirs, ":") {
+		if dir != "" {
+			output = append(output, fmt.Sprintf("%s/nwgocc", dir))
+		}
+	}
+	return output
+}
+
+// Returns path to the user's config file if found, or to the first system-wide copy, or "" if none exists
+func findConfigFile(name string) string {
+	for _, dir := range append([]string{configDir()}, systemConfigDirs()...) {
+		path := fmt.Sprintf("%s/%s", dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// Returns paths to `config.d/*.json` fragments, sorted by file name. A user's fragment overrides
+// a system-wide one of the same name.
+func configFragments() []string {
+	found := make(map[string]string)
+	dirs := append([]string{configDir()}, systemConfigDirs()...)
+	for i := len(dirs) - 1; i >= 0; i-- {
+		paths, _ := filepath.Glob(fmt.Sprintf("%s/config.d/*.json", dirs[i]))
+		for _, path := range paths {
+			found[filepath.Base(path)] = path
+		}
+	}
+	var names []string
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var output []string
+	for _, name := range names {
+		output = append(output, found[name])
+	}
+	return output
+}
+
 func setupDirs() {
 	cDir := configDir()
 	dDir := dataDir()
@@ -41,30 +103,35 @@ func setupDirs() {
 
 	// Create config dir if not found (contains CLI commands, templates, CSS)
 	createDir(cDir)
-	// copy files if not found
-	copyFile("/usr/share/nwgocc/cli_commands", fmt.Sprintf("%s/cli_commands", cDir))
-	copyFile("/usr/share/nwgocc/config.json", fmt.Sprintf("%s/%s", cDir, *configFile))
-	copyFile("/usr/share/nwgocc/style.css", fmt.Sprintf("%s/style.css", cDir))
+	// copy files if not found, unless the admin provided system-wide ones
+	if findConfigFile("cli_commands") == "" {
+		copyAsset("cli_commands", fmt.Sprintf("%s/cli_commands", cDir))
+	}
+	if findConfigFile(*configFile) == "" {
+		copyAsset("config.json", fmt.Sprintf("%s/%s", cDir, *configFile))
+	}
+	if findConfigFile("style.css") == "" {
+		copyAsset("style.css", fmt.Sprintf("%s/style.css", cDir))
+	}
+
+	// Create state dir if not found (things to remember between sessions)
+	createDir(stateDir())
 
 	// Create data dir if not found (contains icons_light/, icons_dark/, preferences.json)
 	createDir(dDir)
-	copyFile("/usr/share/nwgocc/preferences.json", fmt.Sprintf("%s/preferences.json", dDir))
+	copyAsset("preferences.json", fmt.Sprintf("%s/preferences.json", dDir))
 
 	createDir(iconsLightDir)
 
 	// Copy missing icons
-	files, err := ioutil.ReadDir("/usr/share/nwgocc/icons_light")
-	check(err)
-	for _, file := range files {
-		copyFile(fmt.Sprintf("/usr/share/nwgocc/icons_light/%s", file.Name()), fmt.Sprintf("%s/%s", iconsLightDir, file.Name()))
+	for _, name := range listAssets("icons_light") {
+		copyAsset(fmt.Sprintf("icons_light/%s", name), fmt.Sprintf("%s/%s", iconsLightDir, name))
 	}
 
 	createDir(iconsDarkDir)
 
-	files, err = ioutil.ReadDir("/usr/share/nwgocc/icons_dark")
-	check(err)
-	for _, file := range files {
-		copyFile(fmt.Sprintf("/usr/share/nwgocc/icons_dark/%s", file.Name()), fmt.Sprintf("%s/%s", iconsDarkDir, file.Name()))
+	for _, name := range listAssets("icons_dark") {
+		copyAsset(fmt.Sprintf("icons_dark/%s", name), fmt.Sprintf("%s/%s", iconsDarkDir, name))
 	}
 }
 
@@ -79,11 +146,10 @@ func createDir(dir string) {
 	}
 }
 
+// Copies src to dst, if dst not found
 func copyFile(src, dst string) error {
-	if !*restoreDefaults {
-		if _, err := os.Stat(dst); !os.IsNotExist(err) {
-			return err
-		}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		return err
 	}
 	fmt.Println("Copying file:", dst)
 
@@ -113,16 +179,26 @@ func copyFile(src, dst string) error {
 
 // CustomRow contains fields of a single user-defined row
 type CustomRow struct {
-	Name    string `json:"name"`
-	Command string `json:"cmd"`
-	Icon    string `json:"icon"`
+	Name      string            `json:"name"`
+	Command   string            `json:"cmd"`
+	Icon      string            `json:"icon"`
+	Key       string            `json:"key,omitempty"`
+	DesktopID string            `json:"desktop_id,omitempty"` // .desktop file the entry follows
+	Actions   map[string]string `json:"actions,omitempty"`    // by trigger, e.g. "middle"
+	Confirm   bool              `json:"confirm,omitempty"`    // ask before running the command
+	Message   string            `json:"message,omitempty"`    // confirmation message, e.g. "Shutting down"
 }
 
 // Button contains fields of a single user-defined button
 type Button struct {
-	Name    string `json:"name"`
-	Command string `json:"cmd"`
-	Icon    string `json:"icon"`
+	Name      string            `json:"name"`
+	Command   string            `json:"cmd"`
+	Icon      string            `json:"icon"`
+	Key       string            `json:"key,omitempty"`
+	DesktopID string            `json:"desktop_id,omitempty"` // .desktop file the entry follows
+	Actions   map[string]string `json:"actions,omitempty"`    // by trigger, e.g. "middle"
+	Confirm   bool              `json:"confirm,omitempty"`    // ask before running the command
+	Message   string            `json:"message,omitempty"`    // confirmation message, e.g. "Shutting down"
 }
 
 // Configuration stores all the user-defined content: custom rows and buttons
@@ -148,17 +224,40 @@ type Preferences struct {
 	ShowInterfaceLine    bool   `json:"show_interface_line"`
 	ShowUserRows         bool   `json:"show_user_rows"`
 	ShowUserButtons      bool   `json:"show_user_buttons"`
+	ShowProfileSelector  bool   `json:"show_profile_selector"`
 	IconSizeSmall        int    `json:"icon_size_small"`
 	IconSizeLarge        int    `json:"icon_size_large"`
 	RefreshFastMillis    int    `json:"refresh_fast_millis"`
 	RefreshSlowSeconds   int    `json:"refresh_slow_seconds"`
 	RefreshCliSeconds    int    `json:"refresh_cli_seconds"`
-	OnClickUser          string `json:"on-click-user"`
-	OnClickWifi          string `json:"on-click-wifi"`
-	OnClickBluetooth     string `json:"on-click-bluetooth"`
-	OnClickBattery       string `json:"on-click-battery"`
-	OnClickInterface     string `json:"on-click-interface"`
+	ShowOsd              bool   `json:"show_osd"`
+	OsdTimeoutMillis     int    `json:"osd_timeout_millis"`
+	LayerShell           bool   `json:"layer_shell"`
+	Anchor               string `json:"anchor"`
+	MarginTop            int    `json:"margin_top"`
+	MarginRight          int    `json:"margin_right"`
+	MarginBottom         int    `json:"margin_bottom"`
+	MarginLeft           int    `json:"margin_left"`
+	KeyboardExclusive    bool   `json:"keyboard_exclusive"`
+	CloseOnFocusOut      bool   `json:"close_on_focus_out"`
+	AutoHideSeconds      int    `json:"auto_hide_seconds"`
+	SearchDesktopApps    bool   `json:"search_desktop_apps"`
+	ConfirmSeconds       int    `json:"confirm_seconds"`
+	ShowPowerButtons     bool   `json:"show_power_buttons"`
+	ConfirmPower         bool   `json:"confirm_power"`
+	LockCommand          string `json:"lock_command"`
+	LogoutCommand        string `json:"logout_command"`
 	InterfaceName        string `json:"interface-name"`
+
+	// Row actions by row ID and trigger
+	Actions map[string]map[string]string `json:"actions"`
+
+	// Replaced with Actions, only read to migrate older preferences
+	OnClickUser      string `json:"on-click-user,omitempty"`
+	OnClickWifi      string `json:"on-click-wifi,omitempty"`
+	OnClickBluetooth string `json:"on-click-bluetooth,omitempty"`
+	OnClickBattery   string `json:"on-click-battery,omitempty"`
+	OnClickInterface string `json:"on-click-interface,omitempty"`
 }
 
 // Icons store icon definitions
@@ -191,43 +290,270 @@ type Icons struct {
 
 // Commands store external commands
 type Commands struct {
-	GetBattery         string `json:"get_battery"`
-	GetBatteryAlt      string `json:"get_battery_alt"`
-	GetBluetoothName   string `json:"get_bt_name"`
-	GetBluetoothStatus string `json:"get_bt_status"`
-	GetBrightness      string `json:"get_brightness"`
-	GetHost            string `json:"get_host"`
-	GetSsid            string `json:"get_ssid"`
-	GetUser            string `json:"get_user"`
-	SetBrightness      string `json:"set_brightness"`
-	Systemctl          string `json:"systemctl"`
-	Playerctl          string `json:"playerctl"`
-}
-
-// Settings store user preferecnces, icon definitions and external commands
+	GetBattery          string `json:"get_battery"`
+	GetBatteryAlt       string `json:"get_battery_alt"`
+	GetBluetoothName    string `json:"get_bt_name"`
+	GetBluetoothStatus  string `json:"get_bt_status"`
+	GetBluetoothDevices string `json:"get_bt_devices"`
+	GetBrightness       string `json:"get_brightness"`
+	GetHost             string `json:"get_host"`
+	GetSsid             string `json:"get_ssid"`
+	GetUser             string `json:"get_user"`
+	SetBrightness       string `json:"set_brightness"`
+	Systemctl           string `json:"systemctl"`
+	Playerctl           string `json:"playerctl"`
+}
+
+// Notifications define which state transitions get notified, when running in the background
+type Notifications struct {
+	Enabled         bool `json:"enabled"`
+	BatteryLow      int  `json:"battery_low"`
+	BatteryCritical int  `json:"battery_critical"`
+	Charger         bool `json:"charger"`
+	Wifi            bool `json:"wifi"`
+	Bluetooth       bool `json:"bluetooth"`
+	CooldownSeconds int  `json:"cooldown_seconds"`
+}
+
+// Settings store user preferecnces, icon definitions, external commands, rules, notifications and hooks
 type Settings struct {
-	Preferences Preferences `json:"preferences"`
-	Icons       Icons       `json:"icons"`
-	Commands    Commands    `json:"commands"`
+	Preferences   Preferences   `json:"preferences"`
+	Icons         Icons         `json:"icons"`
+	Commands      Commands      `json:"commands"`
+	Rules         []Rule        `json:"rules,omitempty"`
+	Notifications Notifications `json:"notifications"`
+	Hooks         []Hook        `json:"hooks,omitempty"`
+}
+
+// Returns names of templates files (profiles) found in user's and system-wide config dirs
+func listProfiles() []string {
+	found := make(map[string]bool)
+	var output []string
+	for _, dir := range append([]string{configDir()}, systemConfigDirs()...) {
+		paths, _ := filepath.Glob(fmt.Sprintf("%s/*.json", dir))
+		for _, path := range paths {
+			name := filepath.Base(path)
+			if !found[name] {
+				found[name] = true
+				output = append(output, name)
+			}
+		}
+	}
+	sort.Strings(output)
+
+	return output
+}
+
+// Returns css file name to use with the current profile: given with `-s`, `<profile>.css` if found,
+// `style.css` otherwise
+func profileCssFile() string {
+	if isFlagSet("s") {
+		return *cssFile
+	}
+	css := fmt.Sprintf("%s.css", strings.TrimSuffix(*configFile, ".json"))
+	if findConfigFile(css) != "" {
+		return css
+	}
+	return *cssFile
+}
+
+// Returns the profile selected last time, or "" if none
+func loadLastProfile() string {
+	profile, err := readTextFile(fmt.Sprintf("%s/profile", stateDir()))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(profile)
+}
+
+func saveLastProfile(profile string) {
+	createDir(stateDir())
+	err := ioutil.WriteFile(fmt.Sprintf("%s/profile", stateDir()), []byte(profile), 0644)
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// templateLayers keeps what loadConfig merged below and above the user's templates file
+type templateLayers struct {
+	system    Configuration // system-wide copies
+	fragments Configuration // config.d fragments
+	loaded    Configuration // the result, before commands and icons got refreshed from .desktop files
+	broken    string        // the user's file, if it couldn't be parsed; saveConfig won't overwrite it
 }
 
-// Parses the config.json file and returns Configuration instance
+// Layers of the templates currently loaded, so that saveConfig writes back the user's layer only
+var configLayers templateLayers
+
+// Parses the config.json file and returns Configuration instance. System-wide copies of the file
+// ($XDG_CONFIG_DIRS/nwgocc) are loaded first, and the user's one on top of them; keys found in a higher
+// layer replace lower ones. At last `config.d/*.json` fragments are merged in lexical order.
+// A file that fails to parse is skipped.
 func loadConfig() (Configuration, error) {
-	path := fmt.Sprintf("%s/%s", configDir(), *configFile)
-	bytes, err := ioutil.ReadFile(path)
-	check(err)
+	var c Configuration
+	var layers templateLayers
+	found := false
+	paths := []string{fmt.Sprintf("%s/%s", configDir(), *configFile)}
+	for _, dir := range systemConfigDirs() {
+		paths = append(paths, fmt.Sprintf("%s/%s", dir, *configFile))
+	}
+	for i := len(paths) - 1; i >= 0; i-- {
+		layer, err := readConfigFile(paths[i])
+		if err != nil {
+			if !os.IsNotExist(err) {
+				fmt.Println(err)
+				if i == 0 {
+					// keep it for the user to fix, system-wide templates go instead
+					layers.broken = paths[i]
+				}
+			}
+			continue
+		}
+		if i > 0 {
+			overlayConfig(&layers.system, layer)
+		}
+		overlayConfig(&c, layer)
+		found = true
+	}
+	if !found {
+		return c, fmt.Errorf("%s not found", *configFile)
+	}
 
+	for _, path := range configFragments() {
+		fragment, err := readConfigFile(path)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		mergeConfig(&layers.fragments, fragment)
+	}
+	mergeConfig(&c, layers.fragments)
+
+	overlayConfig(&layers.loaded, c)
+	configLayers = layers
+	refreshDesktopReferences(&c)
+
+	return c, nil
+}
+
+// Parses a single templates file
+func readConfigFile(path string) (Configuration, error) {
 	var c Configuration
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
 	err = json.Unmarshal(bytes, &c)
-	check(err)
-
+	if err != nil {
+		return c, fmt.Errorf("%s: %s", path, err)
+	}
 	return c, nil
 }
 
-// Saves current Configuration to a json file
+// Replaces lists of the configuration with copies of these the layer defines
+func overlayConfig(c *Configuration, layer Configuration) {
+	if layer.CustomRows != nil {
+		c.CustomRows = append(make([]CustomRow, 0, len(layer.CustomRows)), layer.CustomRows...)
+	}
+	if layer.Buttons != nil {
+		c.Buttons = append(make([]Button, 0, len(layer.Buttons)), layer.Buttons...)
+	}
+}
+
+// Merges a config.d fragment: entries replace existing ones of the same name, new ones get appended
+func mergeConfig(c *Configuration, fragment Configuration) {
+	for _, row := range fragment.CustomRows {
+		replaced := false
+		for i := range c.CustomRows {
+			if c.CustomRows[i].Name == row.Name {
+				c.CustomRows[i] = row
+				replaced = true
+			}
+		}
+		if !replaced {
+			c.CustomRows = append(c.CustomRows, row)
+		}
+	}
+	for _, btn := range fragment.Buttons {
+		replaced := false
+		for i := range c.Buttons {
+			if c.Buttons[i].Name == btn.Name {
+				c.Buttons[i] = btn
+				replaced = true
+			}
+		}
+		if !replaced {
+			c.Buttons = append(c.Buttons, btn)
+		}
+	}
+}
+
+// userTemplates is the content of the user's templates file; a list left nil comes from system-wide copies
+type userTemplates struct {
+	CustomRows *[]CustomRow `json:"custom_rows,omitempty"`
+	Buttons    *[]Button    `json:"buttons,omitempty"`
+}
+
+// Returns the user's layer of the configuration: entries following .desktop files keep commands and icons
+// as loaded, entries coming unchanged from config.d fragments are left out, and so are lists equal
+// to the system-wide ones
+func userLayer(c Configuration, layers templateLayers) userTemplates {
+	var u userTemplates
+
+	rows := []CustomRow{}
+	for _, row := range c.CustomRows {
+		for _, loaded := range layers.loaded.CustomRows {
+			if row.DesktopID != "" && loaded.Name == row.Name && loaded.DesktopID == row.DesktopID {
+				row.Command, row.Icon = loaded.Command, loaded.Icon
+			}
+		}
+		fromFragment := false
+		for _, fragmentRow := range layers.fragments.CustomRows {
+			if reflect.DeepEqual(fragmentRow, row) {
+				fromFragment = true
+			}
+		}
+		if !fromFragment {
+			rows = append(rows, row)
+		}
+	}
+	if len(rows) != len(layers.system.CustomRows) ||
+		len(rows) > 0 && !reflect.DeepEqual(rows, layers.system.CustomRows) {
+		u.CustomRows = &rows
+	}
+
+	buttons := []Button{}
+	for _, btn := range c.Buttons {
+		for _, loaded := range layers.loaded.Buttons {
+			if btn.DesktopID != "" && loaded.Name == btn.Name && loaded.DesktopID == btn.DesktopID {
+				btn.Command, btn.Icon = loaded.Command, loaded.Icon
+			}
+		}
+		fromFragment := false
+		for _, fragmentBtn := range layers.fragments.Buttons {
+			if reflect.DeepEqual(fragmentBtn, btn) {
+				fromFragment = true
+			}
+		}
+		if !fromFragment {
+			buttons = append(buttons, btn)
+		}
+	}
+	if len(buttons) != len(layers.system.Buttons) ||
+		len(buttons) > 0 && !reflect.DeepEqual(buttons, layers.system.Buttons) {
+		u.Buttons = &buttons
+	}
+
+	return u
+}
+
+// Saves the user's layer of current Configuration to a json file
 func saveConfig() error {
 	path := fmt.Sprintf("%s/%s", configDir(), *configFile)
-	bytes, err := json.MarshalIndent(config, "", "  ")
+	if configLayers.broken == path {
+		return fmt.Errorf("%s could not be parsed, not overwriting it; fix or remove it first", path)
+	}
+	bytes, err := json.MarshalIndent(userLayer(config, configLayers), "", "  ")
 	if err != nil {
 		return err
 	}
@@ -235,8 +561,13 @@ func saveConfig() error {
 	return ioutil.WriteFile(path, bytes, 0644)
 }
 
+// Keys found in the "preferences" section of the last preferences.json loaded, so that newly introduced settings
+// whose zero value means something (e.g. `"confirm_power": false`) can tell missing from set
+var preferenceKeys map[string]json.RawMessage
+
 // Parses the preferences.json file and returns Settings instance
 func loadSettings() (Settings, error) {
+	preferenceKeys = nil
 	path := fmt.Sprintf("%s/preferences.json", dataDir())
 	bytes, err := ioutil.ReadFile(path)
 	if err != nil {
@@ -249,6 +580,12 @@ func loadSettings() (Settings, error) {
 		return Settings{}, err
 	}
 
+	var keys struct {
+		Preferences map[string]json.RawMessage `json:"preferences"`
+	}
+	json.Unmarshal(bytes, &keys)
+	preferenceKeys = keys.Preferences
+
 	return s, nil
 }
 
@@ -271,13 +608,43 @@ func checkMissingSettings() {
 	if settings.Icons.NetworkDisonnected == "" {
 		settings.Icons.NetworkDisonnected = "network-wired-disconnected-symbolic"
 	}
+	if settings.Preferences.OsdTimeoutMillis == 0 {
+		settings.Preferences.OsdTimeoutMillis = 1500
+	}
+	if settings.Commands.GetBluetoothDevices == "" {
+		settings.Commands.GetBluetoothDevices = "bluetoothctl devices Connected | cut -d' ' -f3-"
+	}
+	if _, ok := preferenceKeys["confirm_seconds"]; !ok {
+		settings.Preferences.ConfirmSeconds = 5
+	}
+	if _, ok := preferenceKeys["confirm_power"]; !ok {
+		settings.Preferences.ConfirmPower = true
+	}
+	// intervals of 0 would make timers and the status loop spin
+	if settings.Preferences.RefreshFastMillis < 100 {
+		settings.Preferences.RefreshFastMillis = 100
+	}
+	if settings.Preferences.RefreshSlowSeconds < 1 {
+		settings.Preferences.RefreshSlowSeconds = 1
+	}
+	if settings.Preferences.RefreshCliSeconds < 1 {
+		settings.Preferences.RefreshCliSeconds = 1
+	}
+	migrateOnClick(&settings.Preferences)
+	// section missing in preferences.json older than notifications
+	if settings.Notifications == (Notifications{}) {
+		settings.Notifications = Notifications{BatteryLow: 20, BatteryCritical: 10, Charger: true, Wifi: true,
+			Bluetooth: true, CooldownSeconds: 60}
+	}
 }
 
 // Parses the cli_commands txt file and returns shell commands as []string slice
 func loadCliCommands() []string {
-	path := fmt.Sprintf("%s/cli_commands", configDir())
+	path := findConfigFile("cli_commands")
 	bytes, err := ioutil.ReadFile(path)
-	check(err)
+	if err != nil {
+		return nil
+	}
 	lines := strings.Split(string(bytes), "\n")
 	// trim whitespaces, remove commented out and empty lines
 	var output []string
@@ -363,7 +730,11 @@ func checkCommands(commands Commands) {
 }
 
 func isCommand(command string) bool {
-	cmd := strings.Fields(command)[0]
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return false
+	}
+	cmd := fields[0]
 	return getCommandOutput(fmt.Sprintf("command -v %s ", cmd)) != ""
 }
 
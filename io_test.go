@@ -0,0 +1,238 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMergeConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		c        Configuration
+		fragment Configuration
+		want     Configuration
+	}{
+		{
+			name:     "empty fragment",
+			c:        Configuration{CustomRows: []CustomRow{{Name: "a", Command: "a"}}},
+			fragment: Configuration{},
+			want:     Configuration{CustomRows: []CustomRow{{Name: "a", Command: "a"}}},
+		},
+		{
+			name:     "new entries appended",
+			c:        Configuration{CustomRows: []CustomRow{{Name: "a"}}, Buttons: []Button{{Name: "x"}}},
+			fragment: Configuration{CustomRows: []CustomRow{{Name: "b"}}, Buttons: []Button{{Name: "y"}}},
+			want: Configuration{CustomRows: []CustomRow{{Name: "a"}, {Name: "b"}},
+				Buttons: []Button{{Name: "x"}, {Name: "y"}}},
+		},
+		{
+			name:     "same name replaced in place",
+			c:        Configuration{CustomRows: []CustomRow{{Name: "a", Command: "old"}, {Name: "b"}}},
+			fragment: Configuration{CustomRows: []CustomRow{{Name: "a", Command: "new"}}},
+			want:     Configuration{CustomRows: []CustomRow{{Name: "a", Command: "new"}, {Name: "b"}}},
+		},
+		{
+			name:     "into empty configuration",
+			c:        Configuration{},
+			fragment: Configuration{Buttons: []Button{{Name: "x", Command: "x"}}},
+			want:     Configuration{Buttons: []Button{{Name: "x", Command: "x"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mergeConfig(&tt.c, tt.fragment)
+			if !reflect.DeepEqual(tt.c, tt.want) {
+				t.Errorf("got %+v, want %+v", tt.c, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserLayer(t *testing.T) {
+	system := []CustomRow{{Name: "sys", Command: "sys"}}
+	fragment := CustomRow{Name: "frag", Command: "frag"}
+	desktop := CustomRow{Name: "app", Command: "app --old", Icon: "app", DesktopID: "app.desktop"}
+	refreshed := CustomRow{Name: "app", Command: "app --new", Icon: "app-new", DesktopID: "app.desktop"}
+	layers := templateLayers{
+		system:    Configuration{CustomRows: system},
+		fragments: Configuration{CustomRows: []CustomRow{fragment}},
+		loaded:    Configuration{CustomRows: []CustomRow{system[0], desktop, fragment}},
+	}
+
+	tests := []struct {
+		name     string
+		c        Configuration
+		wantRows *[]CustomRow
+	}{
+		{
+			name:     "unchanged system rows left out",
+			c:        Configuration{CustomRows: []CustomRow{system[0], fragment}},
+			wantRows: nil,
+		},
+		{
+			name:     "edited fragment row kept",
+			c:        Configuration{CustomRows: []CustomRow{system[0], {Name: "frag", Command: "edited"}}},
+			wantRows: &[]CustomRow{system[0], {Name: "frag", Command: "edited"}},
+		},
+		{
+			name:     "all rows removed",
+			c:        Configuration{CustomRows: []CustomRow{}},
+			wantRows: &[]CustomRow{},
+		},
+		{
+			name:     "desktop entry saved as loaded",
+			c:        Configuration{CustomRows: []CustomRow{system[0], refreshed}},
+			wantRows: &[]CustomRow{system[0], desktop},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := userLayer(tt.c, layers)
+			if !reflect.DeepEqual(u.CustomRows, tt.wantRows) {
+				t.Errorf("got %+v, want %+v", u.CustomRows, tt.wantRows)
+			}
+			if u.Buttons != nil {
+				t.Errorf("buttons: got %+v, want none", *u.Buttons)
+			}
+		})
+	}
+}
+
+func TestLoadConfigSkipsBrokenLayer(t *testing.T) {
+	home, system, broken := t.TempDir(), t.TempDir(), t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+	t.Setenv("XDG_CONFIG_DIRS", broken+":"+system)
+	files := map[string]string{
+		broken + "/nwgocc/config.json":          "{ not json",
+		system + "/nwgocc/config.json":          `{"custom_rows": [{"name": "sys", "cmd": "sys"}], "buttons": []}`,
+		home + "/nwgocc/config.json":            `{"buttons": [{"name": "user", "cmd": "user"}]}`,
+		home + "/nwgocc/config.d/10-extra.json": `{"custom_rows": [{"name": "extra", "cmd": "extra"}]}`,
+	}
+	for path, content := range files {
+		createDir(filepath.Dir(path))
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Configuration{
+		CustomRows: []CustomRow{{Name: "sys", Command: "sys"}, {Name: "extra", Command: "extra"}},
+		Buttons:    []Button{{Name: "user", Command: "user"}},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("got %+v, want %+v", c, want)
+	}
+
+	u := userLayer(c, configLayers)
+	if u.CustomRows != nil || u.Buttons == nil || len(*u.Buttons) != 1 {
+		t.Errorf("user layer: got %+v", u)
+	}
+}
+
+func TestBrokenUserConfigNotSaved(t *testing.T) {
+	home, system := t.TempDir(), t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+	t.Setenv("XDG_CONFIG_DIRS", system)
+	user := home + "/nwgocc/config.json"
+	files := map[string]string{
+		system + "/nwgocc/config.json": `{"buttons": [{"name": "sys", "cmd": "sys"}]}`,
+		user:                           `{"buttons": [{"name": "mine", "cmd": "mine"},]}`,
+	}
+	for path, content := range files {
+		createDir(filepath.Dir(path))
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	saved := config
+	defer func() {
+		config = saved
+	}()
+	var err error
+	if config, err = loadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []Button{{Name: "sys", Command: "sys"}}; !reflect.DeepEqual(config.Buttons, want) {
+		t.Errorf("got %+v, want %+v", config.Buttons, want)
+	}
+	config.Buttons = append(config.Buttons, Button{Name: "new", Command: "new"})
+	if err := saveConfig(); err == nil {
+		t.Error("saved over the broken file")
+	}
+	if content, _ := os.ReadFile(user); string(content) != files[user] {
+		t.Errorf("user's file changed: %s", content)
+	}
+}
+
+func TestCheckMissingSettings(t *testing.T) {
+	saved := settings
+	defer func() {
+		settings = saved
+	}()
+	tests := []struct {
+		name        string
+		preferences string
+		wantSeconds int
+		wantPower   bool
+	}{
+		{"missing", `{"preferences": {"icon_size_small": 16}}`, 5, true},
+		{"set to zero", `{"preferences": {"confirm_seconds": 0, "confirm_power": false}}`, 0, false},
+		{"set", `{"preferences": {"confirm_seconds": 10, "confirm_power": true}}`, 10, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Setenv("XDG_DATA_HOME", dir)
+			createDir(filepath.Join(dir, "nwgocc"))
+			if err := os.WriteFile(filepath.Join(dir, "nwgocc/preferences.json"), []byte(tt.preferences), 0644); err != nil {
+				t.Fatal(err)
+			}
+			var err error
+			if settings, err = loadSettings(); err != nil {
+				t.Fatal(err)
+			}
+			checkMissingSettings()
+			if settings.Preferences.ConfirmSeconds != tt.wantSeconds {
+				t.Errorf("confirm_seconds: got %d, want %d", settings.Preferences.ConfirmSeconds, tt.wantSeconds)
+			}
+			if settings.Preferences.ConfirmPower != tt.wantPower {
+				t.Errorf("confirm_power: got %v, want %v", settings.Preferences.ConfirmPower, tt.wantPower)
+			}
+		})
+	}
+}
+
+func TestCheckMissingSettingsIntervals(t *testing.T) {
+	saved := settings
+	defer func() {
+		settings = saved
+	}()
+	tests := []struct {
+		fast, slow, cli             int
+		wantFast, wantSlow, wantCli int
+	}{
+		{0, 0, 0, 100, 1, 1},
+		{-5, -1, -1, 100, 1, 1},
+		{50, 1, 1, 100, 1, 1},
+		{500, 5, 1800, 500, 5, 1800},
+	}
+	for _, tt := range tests {
+		settings = Settings{}
+		settings.Preferences.RefreshFastMillis = tt.fast
+		settings.Preferences.RefreshSlowSeconds = tt.slow
+		settings.Preferences.RefreshCliSeconds = tt.cli
+		checkMissingSettings()
+		p := settings.Preferences
+		if p.RefreshFastMillis != tt.wantFast || p.RefreshSlowSeconds != tt.wantSlow || p.RefreshCliSeconds != tt.wantCli {
+			t.Errorf("%d, %d, %d: got %d, %d, %d", tt.fast, tt.slow, tt.cli,
+				p.RefreshFastMillis, p.RefreshSlowSeconds, p.RefreshCliSeconds)
+		}
+	}
+}
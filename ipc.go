@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/gotk3/gotk3/glib"
+)
+
+// ipcRequest is a single command sent to the running instance, as a line of JSON, e.g.
+// `{"command": "volume", "value": "+5"}` or `{"command": "run", "name": "Lock screen"}`
+type ipcRequest struct {
+	Command string `json:"command"`
+	Value   string `json:"value,omitempty"`
+	Name    string `json:"name,omitempty"`
+}
+
+// ipcResponse is the running instance reply to an ipcRequest
+type ipcResponse struct {
+	Success bool    `json:"success"`
+	Error   string  `json:"error,omitempty"`
+	Status  *Status `json:"status,omitempty"`
+}
+
+const ipcUsage = `Usage: nwgocc msg <command> [argument]
+
+Commands:
+  show | hide | toggle     window visibility
+  volume <value>           set ("50"), adjust ("+5", "-5"), or "mute", "unmute", "toggle-mute"
+  brightness <value>       set ("50") or adjust ("+5", "-5")
+  run <name>               run command of a user row or button
+  osd <kind> [value]       show OSD for "volume" or "brightness", adjusted with the value if given
+  reload                   reload preferences, templates and css
+  status                   print current status as JSON`
+
+// Returns path to the control socket: in $XDG_RUNTIME_DIR if set, in the temp dir otherwise
+func ipcSocketPath() string {
+	return runtimePath("sock")
+}
+
+// Listens on the control socket; each connection carries a single request
+func startIpcServer() error {
+	path := ipcSocketPath()
+	// we hold the lock, so a socket file left over must be stale
+	os.Remove(path)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			go handleIpcConnection(conn)
+		}
+	}()
+	return nil
+}
+
+func stopIpcServer() {
+	os.Remove(ipcSocketPath())
+}
+
+func handleIpcConnection(conn net.Conn) {
+	defer conn.Close()
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return
+	}
+	var request ipcRequest
+	var response ipcResponse
+	if err := json.Unmarshal(line, &request); err != nil {
+		response.Error = fmt.Sprintf("invalid request: %s", err)
+	} else if request.Command == "status" {
+		// no GTK calls here, and we don't want to block the main loop while running commands: commands come from
+		// the settings snapshot
+		status := getStatus()
+		response = ipcResponse{Success: true, Status: &status}
+	} else {
+		response = executeInMainLoop(request)
+	}
+	bytes, _ := json.Marshal(response)
+	conn.Write(append(bytes, '\n'))
+}
+
+// GTK is not thread-safe: executes the request in the main loop and waits for the result
+func executeInMainLoop(request ipcRequest) ipcResponse {
+	done := make(chan ipcResponse)
+	glib.IdleAdd(func() bool {
+		done <- executeIpcRequest(request)
+		return false
+	})
+	return <-done
+}
+
+func executeIpcRequest(request ipcRequest) ipcResponse {
+	var err error
+	switch request.Command {
+	case "show":
+		showWindow()
+	case "hide":
+		closeWindow()
+	case "toggle":
+		toggleWindow()
+	case "volume":
+		err = adjustVolume(request.Value)
+		if err == nil && volRow != nil {
+			updateVolumeRow()
+		}
+	case "brightness":
+		err = adjustBrightness(request.Value)
+		if err == nil && briRow != nil {
+			updateBrightnessRow()
+		}
+	case "run":
+		err = runByName(request.Name)
+	case "osd":
+		err = adjustAndShowOsd(request.Name, request.Value)
+		if err == nil && volRow != nil {
+			updateVolumeRow()
+		}
+		if err == nil && briRow != nil {
+			updateBrightnessRow()
+		}
+	case "reload":
+		reload()
+	default:
+		err = fmt.Errorf("unknown command '%s'", request.Command)
+	}
+	if err != nil {
+		return ipcResponse{Error: err.Error()}
+	}
+	return ipcResponse{Success: true}
+}
+
+// Runs the (left click) action of a user row or button of the given name; if it needs confirmation, shows the window
+// to ask
+func runByName(name string) error {
+	for _, row := range config.CustomRows {
+		if row.Name != name {
+			continue
+		}
+		if action := rowActions(customRowID(name), row.Command, row.Actions)[triggerLeft]; action != "" {
+			runConfirmed(confirmMessage(row.Confirm, row.Message, row.Name), action)
+			return nil
+		}
+	}
+	for _, btn := range config.Buttons {
+		if btn.Name != name {
+			continue
+		}
+		if action := rowActions(customButtonID(name), btn.Command, btn.Actions)[triggerLeft]; action != "" {
+			runConfirmed(confirmMessage(btn.Confirm, btn.Message, btn.Name), action)
+			return nil
+		}
+	}
+	return fmt.Errorf("no row or button named '%s'", name)
+}
+
+// Runs the action, or shows the window to ask first if given a confirmation message
+func runConfirmed(message, action string) {
+	if message != "" {
+		showWindow()
+	}
+	confirmOrRun(message, action)
+}
+
+// Sends a request to the running instance and returns its response
+func sendIpcRequest(request ipcRequest) (ipcResponse, error) {
+	var response ipcResponse
+	conn, err := net.Dial("unix", ipcSocketPath())
+	if err != nil {
+		return response, err
+	}
+	defer conn.Close()
+
+	bytes, _ := json.Marshal(request)
+	_, err = conn.Write(append(bytes, '\n'))
+	if err != nil {
+		return response, err
+	}
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		return response, err
+	}
+	err = json.Unmarshal(line, &response)
+
+	return response, err
+}
+
+// Handles `nwgocc msg <command> [argument]`: sends the command to the running instance, prints the response
+func ipcClient(args []string) {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" {
+		fmt.Println(ipcUsage)
+		os.Exit(0)
+	}
+	request := ipcRequest{Command: args[0]}
+	if len(args) > 1 {
+		switch request.Command {
+		case "run":
+			request.Name = strings.Join(args[1:], " ")
+		case "osd":
+			request.Name = args[1]
+			if len(args) > 2 {
+				request.Value = args[2]
+			}
+		default:
+			request.Value = args[1]
+		}
+	}
+
+	response, err := sendIpcRequest(request)
+	if err != nil {
+		fmt.Printf("Couldn't reach running nwgocc instance: %s\n", err)
+		os.Exit(1)
+	}
+	if response.Status != nil {
+		bytes, _ := json.MarshalIndent(response.Status, "", "  ")
+		fmt.Println(string(bytes))
+	}
+	if !response.Success {
+		fmt.Println(response.Error)
+		os.Exit(1)
+	}
+}
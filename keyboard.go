@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// navItem is a row, slider or button reachable with the keyboard, in the window order
+type navItem struct {
+	name     string
+	key      string // accelerator, from templates
+	widget   *gtk.Widget
+	activate func()
+	adjust   func(delta float64) // sliders only
+}
+
+var navItems []*navItem
+
+// Adds an item to keyboard navigation
+func addNavItem(name, key string, widget *gtk.Widget, activate func(), adjust func(float64)) {
+	widget.SetCanFocus(true)
+	navItems = append(navItems, &navItem{name: name, key: key, widget: widget, activate: activate, adjust: adjust})
+}
+
+// Makes a clickable row focusable, with focus highlighted the same way as on hover
+func addNavRow(name, key string, eventBox *gtk.EventBox, hBox *gtk.Box, styleContext *gtk.StyleContext,
+	activate func()) {
+	eventBox.Connect("focus-in-event", func() {
+		if settings.Preferences.CustomStyling {
+			hBox.SetProperty("name", "row-selected")
+		} else {
+			styleContext.SetState(gtk.STATE_FLAG_SELECTED)
+		}
+	})
+	eventBox.Connect("focus-out-event", func() {
+		if settings.Preferences.CustomStyling {
+			hBox.SetProperty("name", "row-normal")
+		} else {
+			styleContext.SetState(gtk.STATE_FLAG_NORMAL)
+		}
+	})
+	addNavItem(name, key, &eventBox.Widget, activate, nil)
+}
+
+// Adds a slider to keyboard navigation; Left / Right keys move it by 5
+func addNavSlider(name string, slider *gtk.Scale) {
+	addNavItem(name, "", &slider.Widget, nil, func(delta float64) {
+		slider.SetValue(slider.GetValue() + delta)
+	})
+}
+
+// Returns index of the focused item in the list of items currently shown, or -1
+func focusedNavItem(items []*navItem) int {
+	for i, item := range items {
+		if item.widget.HasFocus() {
+			return i
+		}
+	}
+	return -1
+}
+
+// Items not hidden by rules
+func visibleNavItems() []*navItem {
+	var items []*navItem
+	for _, item := range navItems {
+		if item.widget.IsVisible() {
+			parent, err := item.widget.GetParent()
+			// rules hide whole rows, sliders are placed in them
+			if err == nil && parent != nil && !parent.ToWidget().IsVisible() {
+				continue
+			}
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Up / Down move focus over rows, sliders and buttons, Enter / Space activate the focused one, Left / Right adjust
+// the focused slider, Alt + accelerator key activates a row, `?` shows help, other characters start the search
+func handleNavigation(window *gtk.Window, event *gdk.Event) bool {
+	key := &gdk.EventKey{Event: event}
+	if key.State()&uint(gdk.CONTROL_MASK) != 0 {
+		return false
+	}
+	if key.State()&uint(gdk.MOD1_MASK) != 0 {
+		return handleAccelerator(key.KeyVal())
+	}
+	if searchActive() {
+		return handleSearchKey(key.KeyVal())
+	}
+	items := visibleNavItems()
+	current := focusedNavItem(items)
+
+	switch key.KeyVal() {
+	case gdk.KEY_Down, gdk.KEY_Up:
+		if len(items) == 0 {
+			return true
+		}
+		next := 0
+		if key.KeyVal() == gdk.KEY_Down && current >= 0 {
+			next = (current + 1) % len(items)
+		} else if key.KeyVal() == gdk.KEY_Up {
+			next = len(items) - 1
+			if current > 0 {
+				next = current - 1
+			}
+		}
+		items[next].widget.GrabFocus()
+		return true
+	case gdk.KEY_Left, gdk.KEY_Right:
+		if current >= 0 && items[current].adjust != nil {
+			delta := 5.0
+			if key.KeyVal() == gdk.KEY_Left {
+				delta = -5.0
+			}
+			items[current].adjust(delta)
+			return true
+		}
+		return false
+	case gdk.KEY_Return, gdk.KEY_KP_Enter, gdk.KEY_space:
+		if current >= 0 && items[current].activate != nil {
+			items[current].activate()
+			return true
+		}
+		return false
+	case gdk.KEY_question:
+		showKeyboardHelp()
+		return true
+	}
+
+	// any other character starts the search
+	if r := gdk.KeyvalToUnicode(key.KeyVal()); unicode.IsPrint(r) {
+		openSearch(string(r))
+		return true
+	}
+	return false
+}
+
+// Activates the row or button the key is the accelerator of; used with Alt held, as plain characters
+// go to the search
+func handleAccelerator(keyVal uint) bool {
+	if searchActive() {
+		return false
+	}
+	char := string(gdk.KeyvalToUnicode(gdk.KeyvalToLower(keyVal)))
+	for _, item := range visibleNavItems() {
+		if item.key != "" && strings.ToLower(item.key) == char && item.activate != nil {
+			item.activate()
+			return true
+		}
+	}
+	return false
+}
+
+// Shows keys in a popover over the window content
+func showKeyboardHelp() {
+	lines := []string{
+		"Up / Down\tselect",
+		"Enter / Space\tactivate",
+		"Left / Right\tadjust slider",
+		"Typing\tsearch",
+		"Esc\tclose",
+	}
+	for _, item := range visibleNavItems() {
+		if item.key != "" {
+			lines = append(lines, fmt.Sprintf("Alt+%s\t%s", item.key, item.name))
+		}
+	}
+	label, _ := gtk.LabelNew(strings.Join(lines, "\n"))
+	label.SetProperty("margin", 10)
+	popover, _ := gtk.PopoverNew(contentBox)
+	popover.SetProperty("name", "keyboard-help")
+	popover.Add(label)
+	label.Show()
+	popover.Popup()
+}
@@ -0,0 +1,71 @@
+//go:build layershell
+
+package main
+
+// #cgo pkg-config: gtk-layer-shell-0
+// #include <stdlib.h>
+// #include <gtk-layer-shell.h>
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+func gboolean(b bool) C.gboolean {
+	if b {
+		return C.gboolean(1)
+	}
+	return C.gboolean(0)
+}
+
+func layerShellWindow(w *gtk.Window) *C.GtkWindow {
+	return (*C.GtkWindow)(unsafe.Pointer(w.GObject))
+}
+
+// Checks if the compositor supports the layer shell protocol
+func layerShellSupported() bool {
+	return C.gtk_layer_is_supported() != 0
+}
+
+// Turns the window into an overlay layer surface, anchored to given edges, with margins given as top, right,
+// bottom, left. Must be called before the window gets realized.
+func setupLayerShell(w *gtk.Window, anchor string, margins [4]int, exclusiveKeyboard bool) bool {
+	if !layerShellSupported() {
+		return false
+	}
+	window := layerShellWindow(w)
+	C.gtk_layer_init_for_window(window)
+	namespace := C.CString("nwgocc")
+	defer C.free(unsafe.Pointer(namespace))
+	C.gtk_layer_set_namespace(window, namespace)
+	C.gtk_layer_set_layer(window, C.GTK_LAYER_SHELL_LAYER_OVERLAY)
+
+	setLayerShellPosition(w, anchor, margins)
+
+	if exclusiveKeyboard {
+		C.gtk_layer_set_keyboard_mode(window, C.GTK_LAYER_SHELL_KEYBOARD_MODE_EXCLUSIVE)
+	} else {
+		C.gtk_layer_set_keyboard_mode(window, C.GTK_LAYER_SHELL_KEYBOARD_MODE_NONE)
+	}
+	return true
+}
+
+// Anchors the layer surface to given edges, with margins given as top, right, bottom, left
+func setLayerShellPosition(w *gtk.Window, anchor string, margins [4]int) {
+	window := layerShellWindow(w)
+	edges := []C.GtkLayerShellEdge{C.GTK_LAYER_SHELL_EDGE_TOP, C.GTK_LAYER_SHELL_EDGE_RIGHT,
+		C.GTK_LAYER_SHELL_EDGE_BOTTOM, C.GTK_LAYER_SHELL_EDGE_LEFT}
+	anchored := anchorEdges(anchor)
+	for i, edge := range edges {
+		C.gtk_layer_set_anchor(window, edge, gboolean(anchored[i]))
+		C.gtk_layer_set_margin(window, edge, C.int(margins[i]))
+	}
+}
+
+// Moves the layer surface to the monitor
+func setLayerShellMonitor(w *gtk.Window, monitor *gdk.Monitor) {
+	C.gtk_layer_set_monitor(layerShellWindow(w), (*C.GdkMonitor)(unsafe.Pointer(monitor.GObject)))
+}
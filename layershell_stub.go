@@ -0,0 +1,21 @@
+//go:build !layershell
+
+package main
+
+import (
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// Built without gtk-layer-shell (`go build -tags layershell` to have it)
+func layerShellSupported() bool {
+	return false
+}
+
+func setupLayerShell(w *gtk.Window, anchor string, margins [4]int, exclusiveKeyboard bool) bool {
+	return false
+}
+
+func setLayerShellPosition(w *gtk.Window, anchor string, margins [4]int) {}
+
+func setLayerShellMonitor(w *gtk.Window, monitor *gdk.Monitor) {}
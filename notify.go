@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Notification urgency levels, as in the Desktop Notifications Specification
+const (
+	urgencyLow      = byte(0)
+	urgencyNormal   = byte(1)
+	urgencyCritical = byte(2)
+)
+
+var (
+	// when a notification of a given kind was sent last time, and its id, to replace it
+	notificationSent = make(map[string]time.Time)
+	notificationIds  = make(map[string]uint32)
+	notificationLock sync.Mutex
+)
+
+// Returns an icon name, or a path in the custom icons dir, if in use
+func notificationIcon(icon string) string {
+	if iconsDir != "" {
+		return filepath.Join(iconsDir, fmt.Sprintf("%s.svg", icon))
+	}
+	return icon
+}
+
+// Sends a notification through org.freedesktop.Notifications, unless one of the same kind has been sent within
+// the cooldown period. The previous notification of the same kind gets replaced.
+func notify(kind, summary, body, icon string, urgency byte) {
+	notificationLock.Lock()
+	defer notificationLock.Unlock()
+
+	cooldown := time.Duration(settings.Notifications.CooldownSeconds) * time.Second
+	if last, ok := notificationSent[kind]; ok && time.Since(last) < cooldown {
+		return
+	}
+
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		fmt.Println("Notification:", err)
+		return
+	}
+	var id uint32
+	hints := map[string]dbus.Variant{"urgency": dbus.MakeVariant(urgency)}
+	err = conn.Object("org.freedesktop.Notifications", "/org/freedesktop/Notifications").Call(
+		"org.freedesktop.Notifications.Notify", 0, "nwgocc", notificationIds[kind], notificationIcon(icon), summary,
+		body, []string{}, hints, int32(-1)).Store(&id)
+	if err != nil {
+		fmt.Println("Notification:", err)
+		return
+	}
+	notificationSent[kind] = time.Now()
+	notificationIds[kind] = id
+}
+
+// Sends notifications on state transitions enabled in preferences
+func notifyTransitions(previous, current Status) {
+	n := settings.Notifications
+	if !n.Enabled {
+		return
+	}
+	if current.Battery != nil {
+		if batteryDropped(previous, current, n.BatteryCritical) {
+			notify("battery-critical", "Battery critical", fmt.Sprintf("%d%% left", current.Battery.Percentage),
+				settings.Icons.BatteryEmpty, urgencyCritical)
+		} else if batteryDropped(previous, current, n.BatteryLow) {
+			notify("battery-low", "Battery low", fmt.Sprintf("%d%% left", current.Battery.Percentage),
+				settings.Icons.BatteryLow, urgencyNormal)
+		}
+	}
+
+	for _, event := range statusEvents(previous, current) {
+		switch event.Name {
+		case "ac-connected":
+			if n.Charger {
+				notify(event.Name, "Charger connected", fmt.Sprintf("Battery: %d%%", current.Battery.Percentage),
+					batteryIcon(current.Battery.Percentage), urgencyLow)
+			}
+		case "ac-disconnected":
+			if n.Charger {
+				notify(event.Name, "Charger disconnected", fmt.Sprintf("Battery: %d%%", current.Battery.Percentage),
+					batteryIcon(current.Battery.Percentage), urgencyLow)
+			}
+		case "wifi-connected":
+			if n.Wifi {
+				notify(event.Name, "Wi-Fi connected", event.Value, settings.Icons.WifiOn, urgencyLow)
+			}
+		case "wifi-disconnected":
+			if n.Wifi && !current.Wifi.Connected {
+				notify(event.Name, "Wi-Fi disconnected", event.Value, settings.Icons.WifiOff, urgencyNormal)
+			}
+		case "bt-device-connected":
+			if n.Bluetooth {
+				notify("bt-"+event.Value, "Bluetooth device connected", event.Value, settings.Icons.BtOn, urgencyLow)
+			}
+		}
+	}
+}
+
+// Registers the notifications status watcher; it checks if they're enabled each time, as preferences may get reloaded
+func startNotifications() {
+	statusWatchers = append(statusWatchers, notifyTransitions)
+}
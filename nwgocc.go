@@ -7,11 +7,10 @@ import (
 	"os/exec"
 	"os/signal"
 	"path/filepath"
-	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
-	"github.com/allan-simon/go-singleinstance"
 	"github.com/gotk3/gotk3/gdk"
 	"github.com/gotk3/gotk3/glib"
 	"github.com/gotk3/gotk3/gtk"
@@ -35,8 +34,16 @@ var configFile = flag.String("c", "config.json", "user's templates: Config file
 var cssFile = flag.String("s", "style.css", "custom Styling: css file name")
 var debug = flag.Bool("d", false, "Do checks, print results")
 var displayVersion = flag.Bool("v", false, "display Version information")
-var winPosPointer = flag.Bool("p", false, "place window at the mouse Pointer position (Xorg only)")
-var restoreDefaults = flag.Bool("r", false, "Restore defaults (preferences, templates and icons)")
+var winPosPointer = flag.Bool("p", false, "place window at the mouse Pointer position (Xorg, or Hyprland with layer shell)")
+var restoreDefaults = flag.Bool("r", false, "Restore defaults (preferences, templates, css, icons and cli commands)")
+var daemon = flag.Bool("daemon", false, "keep running in the background with the window hidden; next launch toggles it")
+var replaceInstance = flag.Bool("replace", false, "replace the running instance")
+var toggleInstance = flag.Bool("toggle", false, "toggle the window of the running daemon, or close the running instance (default)")
+var newInstance = flag.Bool("new", false, "start a new instance, even if another one is running")
+var trayMode = flag.String("tray", "", "show a tray icon following 'battery' or 'volume' state; implies -daemon")
+var barFormat = flag.String("bar", "", "print status continuously for a bar: 'waybar' or 'i3bar' (sections as arguments)")
+var restoreList = flag.String("restore", "", "Restore defaults of selected components: preferences,templates,css,icons,cli")
+var assumeYes = flag.Bool("y", false, "restore defaults without asking (with -r / -restore)")
 
 // These values need updates
 var (
@@ -67,6 +74,20 @@ var (
 	playImage *gtk.Image
 )
 
+// Window content and rows that need updates; nil if turned off
+var (
+	contentBox   *gtk.Box
+	cliLabel     *gtk.Label
+	briRow       *gtk.Box
+	volRow       *gtk.Box
+	wifiRow      *gtk.EventBox
+	interfaceRow *gtk.EventBox
+	btRow        *gtk.EventBox
+	batRow       *gtk.EventBox
+)
+
+var cssProvider *gtk.CssProvider
+
 var configChanged = false
 var wayland bool
 
@@ -102,13 +123,19 @@ func setupUserRow() *gtk.EventBox {
 	label, _ := gtk.LabelNew(name)
 	hBox.PackStart(label, false, false, 2)
 
-	if settings.Preferences.OnClickUser != "" {
-		pixbuf := createPixbuf(settings.Icons.ClickMe, settings.Preferences.IconSizeSmall)
-		image, _ := gtk.ImageNewFromPixbuf(pixbuf)
-		hBox.PackEnd(image, false, false, 2)
+	actions := rowActions(rowUser, "", nil)
+	if len(actions) > 0 {
+		if actions[triggerLeft] != "" {
+			pixbuf := createPixbuf(settings.Icons.ClickMe, settings.Preferences.IconSizeSmall)
+			image, _ := gtk.ImageNewFromPixbuf(pixbuf)
+			hBox.PackEnd(image, false, false, 2)
+		}
 
-		eventBox.Connect("button-press-event", func() {
-			launchCommand(settings.Preferences.OnClickUser)
+		connectActions(&eventBox.Widget, actions, func() {
+			runAction(actions[triggerLeft])
+		})
+		addNavRow(rowUser, "", eventBox, hBox, styleContext, func() {
+			runAction(actions[triggerLeft])
 		})
 		eventBox.Connect("enter-notify-event", func() {
 			if settings.Preferences.CustomStyling {
@@ -141,7 +168,7 @@ func setupWifiRow() *gtk.EventBox {
 		hBox.SetProperty("name", "row-normal")
 	}
 
-	ssid := fmt.Sprintf("%s", getCommandOutput(settings.Commands.GetSsid))
+	ssid := getWifiStatus(settings.Commands).Ssid
 	wifiIcon = settings.Icons.WifiOff
 	var wifiText string
 	if ssid != "" {
@@ -159,13 +186,19 @@ func setupWifiRow() *gtk.EventBox {
 	wifiLabel.SetText(wifiText)
 	hBox.PackStart(wifiLabel, false, false, 2)
 
-	if settings.Preferences.OnClickWifi != "" {
-		pixbuf := createPixbuf(settings.Icons.ClickMe, settings.Preferences.IconSizeSmall)
-		image, _ := gtk.ImageNewFromPixbuf(pixbuf)
-		hBox.PackEnd(image, false, false, 2)
+	actions := rowActions(rowWifi, "", nil)
+	if len(actions) > 0 {
+		if actions[triggerLeft] != "" {
+			pixbuf := createPixbuf(settings.Icons.ClickMe, settings.Preferences.IconSizeSmall)
+			image, _ := gtk.ImageNewFromPixbuf(pixbuf)
+			hBox.PackEnd(image, false, false, 2)
+		}
 
-		eventBox.Connect("button-press-event", func() {
-			launchCommand(settings.Preferences.OnClickWifi)
+		connectActions(&eventBox.Widget, actions, func() {
+			runAction(actions[triggerLeft])
+		})
+		addNavRow(rowWifi, "", eventBox, hBox, styleContext, func() {
+			runAction(actions[triggerLeft])
 		})
 		eventBox.Connect("enter-notify-event", func() {
 			if settings.Preferences.CustomStyling {
@@ -189,7 +222,7 @@ func setupWifiRow() *gtk.EventBox {
 }
 
 func updateWifiRow() {
-	ssid := fmt.Sprintf("%s", getCommandOutput(settings.Commands.GetSsid))
+	ssid := getWifiStatus(settings.Commands).Ssid
 	icon := ""
 	var status string
 	if ssid != "" {
@@ -246,13 +279,19 @@ func setupInterfaceRow() *gtk.EventBox {
 	interfaceLabel, _ = gtk.LabelNew(interfaceText)
 	hBox.PackStart(interfaceLabel, false, false, 2)
 
-	if settings.Preferences.OnClickInterface != "" {
-		pixbuf := createPixbuf(settings.Icons.ClickMe, settings.Preferences.IconSizeSmall)
-		image, _ := gtk.ImageNewFromPixbuf(pixbuf)
-		hBox.PackEnd(image, false, false, 2)
+	actions := rowActions(rowInterface, "", nil)
+	if len(actions) > 0 {
+		if actions[triggerLeft] != "" {
+			pixbuf := createPixbuf(settings.Icons.ClickMe, settings.Preferences.IconSizeSmall)
+			image, _ := gtk.ImageNewFromPixbuf(pixbuf)
+			hBox.PackEnd(image, false, false, 2)
+		}
 
-		eventBox.Connect("button-press-event", func() {
-			launchCommand(settings.Preferences.OnClickInterface)
+		connectActions(&eventBox.Widget, actions, func() {
+			runAction(actions[triggerLeft])
+		})
+		addNavRow(rowInterface, "", eventBox, hBox, styleContext, func() {
+			runAction(actions[triggerLeft])
 		})
 		eventBox.Connect("enter-notify-event", func() {
 			if settings.Preferences.CustomStyling {
@@ -306,11 +345,12 @@ func setupBluetoothRow() *gtk.EventBox {
 		hBox.SetProperty("name", "row-normal")
 	}
 
-	btOn := fmt.Sprintf("%s", getCommandOutput(settings.Commands.GetBluetoothStatus)) == "yes"
+	bt := getBluetoothStatus(settings.Commands, false)
+	btOn := bt.Powered
 	var status string
 	if btOn {
 		btIcon = settings.Icons.BtOn
-		status = fmt.Sprintf("%s", getCommandOutput(settings.Commands.GetBluetoothName))
+		status = bt.Name
 	} else {
 		btIcon = settings.Icons.BtOff
 		status = "disabled"
@@ -324,13 +364,19 @@ func setupBluetoothRow() *gtk.EventBox {
 	btLabel.SetText(status)
 	hBox.PackStart(btLabel, false, false, 2)
 
-	if settings.Preferences.OnClickBluetooth != "" {
-		pixbuf := createPixbuf(settings.Icons.ClickMe, settings.Preferences.IconSizeSmall)
-		image, _ := gtk.ImageNewFromPixbuf(pixbuf)
-		hBox.PackEnd(image, false, false, 2)
+	actions := rowActions(rowBluetooth, "", nil)
+	if len(actions) > 0 {
+		if actions[triggerLeft] != "" {
+			pixbuf := createPixbuf(settings.Icons.ClickMe, settings.Preferences.IconSizeSmall)
+			image, _ := gtk.ImageNewFromPixbuf(pixbuf)
+			hBox.PackEnd(image, false, false, 2)
+		}
 
-		eventBox.Connect("button-press-event", func() {
-			launchCommand(settings.Preferences.OnClickBluetooth)
+		connectActions(&eventBox.Widget, actions, func() {
+			runAction(actions[triggerLeft])
+		})
+		addNavRow(rowBluetooth, "", eventBox, hBox, styleContext, func() {
+			runAction(actions[triggerLeft])
 		})
 		eventBox.Connect("enter-notify-event", func() {
 			if settings.Preferences.CustomStyling {
@@ -354,12 +400,13 @@ func setupBluetoothRow() *gtk.EventBox {
 }
 
 func updateBluetoothRow() {
-	btOn := fmt.Sprintf("%s", getCommandOutput(settings.Commands.GetBluetoothStatus)) == "yes"
+	bt := getBluetoothStatus(settings.Commands, false)
+	btOn := bt.Powered
 	icon := ""
 	var status string
 	if btOn {
 		icon = settings.Icons.BtOn
-		status = fmt.Sprintf("%s", getCommandOutput(settings.Commands.GetBluetoothName))
+		status = bt.Name
 	} else {
 		icon = settings.Icons.BtOff
 		status = "disabled"
@@ -383,24 +430,9 @@ func setupBatteryRow() *gtk.EventBox {
 		hBox.SetProperty("name", "row-normal")
 	}
 
-	status := ""
-	val := 0
-	if isCommand(settings.Commands.GetBattery) {
-		status, val = getBattery(settings.Commands.GetBattery)
-	} else if isCommand(settings.Commands.GetBatteryAlt) {
-		status, val = getBattery(settings.Commands.GetBatteryAlt)
-	}
+	status, val := readBattery(settings.Commands)
 
-	switch {
-	case val > 95:
-		batIcon = settings.Icons.BatteryFull
-	case val > 50:
-		batIcon = settings.Icons.BatteryGood
-	case val > 20:
-		batIcon = settings.Icons.BatteryLow
-	default:
-		batIcon = settings.Icons.BatteryEmpty
-	}
+	batIcon = batteryIcon(val)
 
 	pixbuf := createPixbuf(batIcon, settings.Preferences.IconSizeSmall)
 	batImage, _ = gtk.ImageNew()
@@ -411,13 +443,19 @@ func setupBatteryRow() *gtk.EventBox {
 	batLabel.SetText(status)
 	hBox.PackStart(batLabel, false, false, 2)
 
-	if settings.Preferences.OnClickBattery != "" {
-		pixbuf := createPixbuf(settings.Icons.ClickMe, settings.Preferences.IconSizeSmall)
-		image, _ := gtk.ImageNewFromPixbuf(pixbuf)
-		hBox.PackEnd(image, false, false, 2)
+	actions := rowActions(rowBattery, "", nil)
+	if len(actions) > 0 {
+		if actions[triggerLeft] != "" {
+			pixbuf := createPixbuf(settings.Icons.ClickMe, settings.Preferences.IconSizeSmall)
+			image, _ := gtk.ImageNewFromPixbuf(pixbuf)
+			hBox.PackEnd(image, false, false, 2)
+		}
 
-		eventBox.Connect("button-press-event", func() {
-			launchCommand(settings.Preferences.OnClickBattery)
+		connectActions(&eventBox.Widget, actions, func() {
+			runAction(actions[triggerLeft])
+		})
+		addNavRow(rowBattery, "", eventBox, hBox, styleContext, func() {
+			runAction(actions[triggerLeft])
 		})
 		eventBox.Connect("enter-notify-event", func() {
 			if settings.Preferences.CustomStyling {
@@ -440,24 +478,8 @@ func setupBatteryRow() *gtk.EventBox {
 }
 
 func updateBatteryRow() {
-	status := ""
-	val := 0
-	if isCommand(settings.Commands.GetBattery) {
-		status, val = getBattery(settings.Commands.GetBattery)
-	} else if isCommand(settings.Commands.GetBatteryAlt) {
-		status, val = getBattery(settings.Commands.GetBatteryAlt)
-	}
-	icon := ""
-	switch {
-	case val > 95:
-		icon = settings.Icons.BatteryFull
-	case val > 50:
-		icon = settings.Icons.BatteryGood
-	case val > 20:
-		icon = settings.Icons.BatteryLow
-	default:
-		icon = settings.Icons.BatteryEmpty
-	}
+	status, val := readBattery(settings.Commands)
+	icon := batteryIcon(val)
 
 	if icon != batIcon {
 		pixbuf := createPixbuf(icon, settings.Preferences.IconSizeSmall)
@@ -473,19 +495,18 @@ func updateBatteryRow() {
 func setupBrightnessRow() *gtk.Box {
 	box, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 10)
 	bri := getBrightness()
-	icon := ""
-	switch {
-	case bri > 70:
-		icon = settings.Icons.BrightnessHigh
-	case bri > 30:
-		icon = settings.Icons.BrightnessMedium
-	default:
-		icon = settings.Icons.BrightnessLow
-	}
+	icon := brightnessIcon(int(bri))
 	pixbuf := createPixbuf(icon, settings.Preferences.IconSizeSmall)
 	briImage, _ = gtk.ImageNew()
 	briImage.SetFromPixbuf(pixbuf)
-	box.PackStart(briImage, false, false, 2)
+	// the icon takes mouse actions, e.g. scroll to adjust
+	eventBox, _ := gtk.EventBoxNew()
+	eventBox.Add(briImage)
+	actions := rowActions(rowBrightness, "", nil)
+	connectActions(&eventBox.Widget, actions, func() {
+		runAction(actions[triggerLeft])
+	})
+	box.PackStart(eventBox, false, false, 2)
 
 	briSlider, _ = gtk.ScaleNewWithRange(gtk.ORIENTATION_HORIZONTAL, 0, 100, 1)
 	briSlider.SetValue(bri)
@@ -495,21 +516,14 @@ func setupBrightnessRow() *gtk.Box {
 	})
 
 	box.PackStart(briSlider, true, true, 2)
+	addNavSlider(rowBrightness, briSlider)
 
 	return box
 }
 
 func updateBrightnessRow() {
 	bri := getBrightness()
-	icon := ""
-	switch {
-	case bri > 70:
-		icon = settings.Icons.BrightnessHigh
-	case bri > 30:
-		icon = settings.Icons.BrightnessMedium
-	default:
-		icon = settings.Icons.BrightnessLow
-	}
+	icon := brightnessIcon(int(bri))
 	if icon != briIcon {
 		pixbuf := createPixbuf(icon, settings.Preferences.IconSizeSmall)
 		briImage.SetFromPixbuf(pixbuf)
@@ -523,24 +537,18 @@ func setupVolumeRow() *gtk.Box {
 	vol, _ := volume.GetVolume()
 	muted, err := volume.GetMuted()
 	check(err)
-	icon := ""
-	if !muted {
-		switch {
-		case vol > 70:
-			icon = settings.Icons.VolumeHigh
-		case vol > 30:
-			icon = settings.Icons.VolumeMedium
-		default:
-			icon = settings.Icons.VolumeLow
-		}
-	} else {
-		icon = settings.Icons.VolumeMuted
-	}
+	icon := volumeIcon(vol, muted)
 
 	pixbuf := createPixbuf(icon, settings.Preferences.IconSizeSmall)
 	volImage, _ = gtk.ImageNew()
 	volImage.SetFromPixbuf(pixbuf)
-	box.PackStart(volImage, false, false, 2)
+	eventBox, _ := gtk.EventBoxNew()
+	eventBox.Add(volImage)
+	actions := rowActions(rowVolume, "", nil)
+	connectActions(&eventBox.Widget, actions, func() {
+		runAction(actions[triggerLeft])
+	})
+	box.PackStart(eventBox, false, false, 2)
 
 	volSlider, _ = gtk.ScaleNewWithRange(gtk.ORIENTATION_HORIZONTAL, 0, 100, 1)
 	volSlider.SetValue(float64(vol))
@@ -551,6 +559,7 @@ func setupVolumeRow() *gtk.Box {
 	})
 
 	box.PackStart(volSlider, true, true, 2)
+	addNavSlider(rowVolume, volSlider)
 
 	if settings.Preferences.ShowPlayerctl && isCommand(settings.Commands.Playerctl) {
 		playerctlStatus := getCommandOutput("playerctl status /dev/null 2>&1")
@@ -604,19 +613,7 @@ func updateVolumeRow() {
 	vol, _ := volume.GetVolume()
 	muted, err := volume.GetMuted()
 	check(err)
-	icon := ""
-	if !muted {
-		switch {
-		case vol > 70:
-			icon = settings.Icons.VolumeHigh
-		case vol > 30:
-			icon = settings.Icons.VolumeMedium
-		default:
-			icon = settings.Icons.VolumeLow
-		}
-	} else {
-		icon = settings.Icons.VolumeMuted
-	}
+	icon := volumeIcon(vol, muted)
 
 	if icon != volIcon {
 		pixbuf := createPixbuf(icon, settings.Preferences.IconSizeSmall)
@@ -642,7 +639,7 @@ func updateVolumeRow() {
 }
 
 // User-defined rows; name, command and icon defined in `~/.config/nwgocc/config.json`
-func setupCustomRow(icon, name, cmd string) *gtk.EventBox {
+func setupCustomRow(icon, name, cmd, key, confirm string, own map[string]string) *gtk.EventBox {
 	eventBox, _ := gtk.EventBoxNew()
 	styleContext, _ := eventBox.GetStyleContext()
 	hBox, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 10)
@@ -663,14 +660,19 @@ func setupCustomRow(icon, name, cmd string) *gtk.EventBox {
 		hBox.PackStart(label, false, false, 2)
 	}
 
-	if cmd != "" {
-		pixbuf := createPixbuf(settings.Icons.ClickMe, settings.Preferences.IconSizeSmall)
-		image, _ := gtk.ImageNewFromPixbuf(pixbuf)
-		hBox.PackEnd(image, false, false, 2)
+	actions := rowActions(customRowID(name), cmd, own)
+	if len(actions) > 0 {
+		if actions[triggerLeft] != "" {
+			pixbuf := createPixbuf(settings.Icons.ClickMe, settings.Preferences.IconSizeSmall)
+			image, _ := gtk.ImageNewFromPixbuf(pixbuf)
+			hBox.PackEnd(image, false, false, 2)
+		}
 
-		eventBox.Connect("button-press-event", func() {
-			launchCommand(cmd)
-		})
+		activate := func() {
+			confirmOrRun(confirm, actions[triggerLeft])
+		}
+		connectActions(&eventBox.Widget, actions, activate)
+		addNavRow(name, key, eventBox, hBox, styleContext, activate)
 		eventBox.Connect("enter-notify-event", func() {
 			if settings.Preferences.CustomStyling {
 				hBox.SetProperty("name", "row-selected")
@@ -710,12 +712,13 @@ func setupPreferencesButton() *gtk.Button {
 	button.Connect("clicked", func() {
 		setupPreferencesWindow()
 	})
+	addNavItem("Preferences", "", &button.Widget, button.Clicked, nil)
 
 	return button
 }
 
 // User-defined buttons; name, command and icon defined in `~/.config/nwgocc/config.json`
-func setupCustomButton(icon, name, cmd string) *gtk.Button {
+func setupCustomButton(icon, name, cmd, key, confirm string, own map[string]string) *gtk.Button {
 	button, _ := gtk.ButtonNew()
 	if settings.Preferences.CustomStyling {
 		button.SetProperty("name", "custom-button")
@@ -727,207 +730,204 @@ func setupCustomButton(icon, name, cmd string) *gtk.Button {
 	if name != "" {
 		button.SetTooltipText(name)
 	}
-	button.Connect("clicked", func() {
-		launchCommand(cmd)
-	})
+	actions := rowActions(customButtonID(name), cmd, own)
+	activate := func() {
+		confirmOrRun(confirm, actions[triggerLeft])
+	}
+	button.Connect("clicked", activate)
+	if actions[triggerDouble] != "" {
+		// the left click has to wait for a possible second one
+		connectActions(&button.Widget, actions, activate)
+	} else {
+		connectActions(&button.Widget, actions, nil)
+	}
+	addNavItem(name, key, &button.Widget, button.Clicked, nil)
 
 	return button
 }
 
-// Exit on Esc key
-func handleKeyboard(window *gtk.Window, event *gdk.Event) {
-	key := &gdk.EventKey{Event: event}
-	if key.KeyVal() == gdk.KEY_Escape {
-		gtk.MainQuit()
+// Loads css file of the current profile, replacing the one loaded before
+func loadCss() {
+	screen, _ := gdk.ScreenGetDefault()
+	if cssProvider != nil {
+		gtk.RemoveProviderForScreen(screen, cssProvider)
 	}
-}
-
-func main() {
-	timeStart := time.Now()
-
-	// Gentle SIGTERM handler thanks to reiki4040 https://gist.github.com/reiki4040/be3705f307d3cd136e85
-	signalChan := make(chan os.Signal, 1)
-	signal.Notify(signalChan, syscall.SIGTERM)
-	go func() {
-		for {
-			s := <-signalChan
-			if s == syscall.SIGTERM {
-				fmt.Println("SIGTERM received, bye bye!")
-				gtk.MainQuit()
-			}
-		}
-	}()
-
-	// We don't want multiple instances. For better user experience (when nwgocc attached to a button or a key binding),
-	// let's kill the running instance and exit.
-	lockFilePath := fmt.Sprintf("%s/nwgocc.lock", tempDir())
-	lockFile, err := singleinstance.CreateLockFile(lockFilePath)
+	css := findConfigFile(profileCssFile())
+	if css == "" {
+		css = filepath.Join(configDir(), profileCssFile())
+	}
+	fmt.Printf("Style: '%s'\n", css)
+	var err error
+	cssProvider, err = gtk.CssProviderNew()
+	check(err)
+	err = cssProvider.LoadFromPath(css)
 	if err != nil {
-		pid, err := readTextFile(lockFilePath)
-		if err == nil {
-			i, err := strconv.Atoi(pid)
-			if err == nil {
-				fmt.Println("Running instance found, sending SIGTERM and exiting...")
-				syscall.Kill(i, syscall.SIGTERM)
-			}
-		}
-		os.Exit(0)
+		fmt.Println(err)
 	}
-	defer lockFile.Close()
-
-	flag.Parse()
+	gtk.AddProviderForScreen(screen, cssProvider, gtk.STYLE_PROVIDER_PRIORITY_USER)
+}
 
-	if *displayVersion {
-		fmt.Printf("nwgocc version %s\n", version)
-		os.Exit(0)
+// Profile selector: templates files found in the config dir
+func setupProfileCombo() *gtk.ComboBoxText {
+	combo, _ := gtk.ComboBoxTextNew()
+	combo.SetProperty("name", "profile-combo")
+	for _, profile := range listProfiles() {
+		combo.Append(profile, strings.TrimSuffix(profile, ".json"))
 	}
+	combo.SetActiveID(*configFile)
+	combo.Connect("changed", func() {
+		profile := combo.GetActiveID()
+		// the combo itself gets destroyed on rebuild, so let the signal handler return first
+		glib.IdleAdd(func() {
+			switchProfile(profile)
+		})
+	})
 
-	wayland = isWayland()
-	fmt.Printf("Wayland: %t\n", wayland)
-
-	setupDirs()
-
-	// Load Preferences, Icons and Commands from ~/.local/share/nwgocc/preferences.json
-	settings, _ = loadSettings()
-	checkMissingSettings()
+	return combo
+}
 
-	// On `-d` check and print commands availability
-	if *debug {
-		checkCommands(settings.Commands)
+// Loads another templates file (and its css, if any), rebuilds the window content and remembers the choice.
+// Templates edited but not saved yet get saved first.
+func switchProfile(profile string) {
+	if profile == "" || profile == *configFile {
+		return
 	}
-
-	// Load user-defined CustomRows and Buttons from ~/.config/config.json
+	if configChanged {
+		if err := saveConfig(); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	*configFile = profile
 	config, _ = loadConfig()
+	configChanged = false
 	fmt.Printf("Templates: '%s'\n", *configFile)
-
-	// Empty means: gtk icons in use
-	iconsDir = ""
-	if settings.Preferences.IconSet == "light" {
-		iconsDir = filepath.Join(dataDir(), "icons_light")
-		fmt.Println("Icons: Custom light")
-	} else if settings.Preferences.IconSet == "dark" {
-		iconsDir = filepath.Join(dataDir(), "icons_dark")
-		fmt.Println("Icons: Custom dark")
-	} else {
-		fmt.Println("Icons: GTK")
-	}
-
-	gtk.Init(nil)
+	saveLastProfile(profile)
 
 	if settings.Preferences.CustomStyling {
-		css := filepath.Join(configDir(), *cssFile)
-		fmt.Printf("Style: '%s'\n", css)
-		cssProvider, err := gtk.CssProviderNew()
-		check(err)
-		err = cssProvider.LoadFromPath(css)
-		if err != nil {
-			fmt.Println(err)
-		}
-		screen, _ := gdk.ScreenGetDefault()
-		gtk.AddProviderForScreen(screen, cssProvider, gtk.STYLE_PROVIDER_PRIORITY_USER)
-	} else {
-		fmt.Println("Style: GTK")
+		loadCss()
 	}
+	reloadContent()
+}
 
-	win, err := gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
-	check(err)
-
-	win.SetTitle("nwgocc: Control Center")
-	if !wayland {
-		err = win.SetIconFromFile("/usr/share/pixmaps/nwgocc.svg")
-		if err != nil {
-			win.SetIconName("nwgocc")
-		}
+// Replaces the window content with a freshly built one
+func reloadContent() {
+	cancelConfirmation()
+	if contentBox != nil {
+		contentBox.Destroy()
 	}
-	win.SetProperty("name", "window")
-	win.SetDecorated(settings.Preferences.WindowDecorations)
-	if *winPosPointer {
-		win.SetPosition(gtk.WIN_POS_MOUSE)
-	}
-	win.Connect("destroy", func() {
-		gtk.MainQuit()
-	})
-
-	win.Connect("key-release-event", handleKeyboard)
+	contentBox = setupContent()
+	win.Add(contentBox)
+	win.ShowAll()
+	applyRules()
+	updateTrayMenu()
+}
 
+// Builds the window content; rows that need updates get assigned to package-level variables
+func setupContent() *gtk.Box {
 	boxOuterV, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 36)
-	win.Add(boxOuterV)
+
+	searchBox = setupSearch()
+	boxOuterV.PackStart(searchBox, false, false, 10)
 
 	boxOuterH, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 36)
 	boxOuterV.PackStart(boxOuterH, false, false, 10)
+	mainContent = boxOuterH
 
 	vBox, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 0)
 	boxOuterH.PackStart(vBox, true, true, 10)
 
-	cliCommands = loadCliCommands()
-	var cliLabel *gtk.Label
+	if settings.Preferences.ShowProfileSelector && len(listProfiles()) > 1 {
+		combo := setupProfileCombo()
+		vBox.PackStart(combo, false, false, 4)
+	}
+
+	cliLabel, briRow, volRow, wifiRow, interfaceRow, btRow, batRow = nil, nil, nil, nil, nil, nil, nil
+	rowWidgets = make(map[string]gtk.IWidget)
+	rowSeparators = make(map[uintptr]bool)
+	rowsBox = vBox
+	navItems = nil
+
 	if settings.Preferences.ShowCliLabel {
 		if len(cliCommands) > 0 {
 			cliLabel = setupCliLabel()
-			vBox.PackStart(cliLabel, true, true, 4)
-			sep, _ := gtk.SeparatorNew(gtk.ORIENTATION_HORIZONTAL)
-			vBox.PackStart(sep, true, true, 6)
+			// in an event box, to take mouse actions
+			eventBox, _ := gtk.EventBoxNew()
+			eventBox.Add(cliLabel)
+			actions := rowActions(rowCli, "", nil)
+			connectActions(&eventBox.Widget, actions, func() {
+				runAction(actions[triggerLeft])
+			})
+			rowWidgets[rowCli] = eventBox
+			vBox.PackStart(eventBox, true, true, 4)
+			packSeparator(vBox)
 		}
 	}
 
-	var briRow *gtk.Box
 	if settings.Preferences.ShowBrightnessSlider {
 		briRow = setupBrightnessRow()
+		rowWidgets[rowBrightness] = briRow
 		vBox.PackStart(briRow, false, false, 4)
 	}
 
-	var volRow *gtk.Box
 	if settings.Preferences.ShowVolumeSlider {
 		volRow = setupVolumeRow()
+		rowWidgets[rowVolume] = volRow
 		vBox.PackStart(volRow, false, false, 4)
 	}
 
 	if settings.Preferences.ShowBrightnessSlider || settings.Preferences.ShowVolumeSlider {
-		sep, _ := gtk.SeparatorNew(gtk.ORIENTATION_HORIZONTAL)
-		vBox.PackStart(sep, true, true, 6)
+		packSeparator(vBox)
 	}
 
 	if settings.Preferences.ShowUserLine {
 		userRow := setupUserRow()
+		rowWidgets[rowUser] = userRow
 		vBox.PackStart(userRow, false, false, 4)
 	}
 
-	var wifiRow *gtk.EventBox
 	if settings.Preferences.ShowWifiLine {
 		wifiRow = setupWifiRow()
+		rowWidgets[rowWifi] = wifiRow
 		vBox.PackStart(wifiRow, false, false, 4)
 	}
 
-	var interfaceRow *gtk.EventBox
 	if settings.Preferences.ShowInterfaceLine {
 		interfaceRow = setupInterfaceRow()
+		rowWidgets[rowInterface] = interfaceRow
 		vBox.PackStart(interfaceRow, false, false, 4)
 	}
 
-	var btRow *gtk.EventBox
 	if settings.Preferences.ShowBtLine && btServiceEnabled() {
 		btRow = setupBluetoothRow()
+		rowWidgets[rowBluetooth] = btRow
 		vBox.PackStart(btRow, false, false, 4)
 	}
 
-	var batRow *gtk.EventBox
 	if settings.Preferences.ShowBatteryLine {
 		batRow = setupBatteryRow()
+		rowWidgets[rowBattery] = batRow
 		vBox.PackStart(batRow, false, false, 4)
 	}
 
 	if settings.Preferences.ShowUserRows {
-		sep, _ := gtk.SeparatorNew(gtk.ORIENTATION_HORIZONTAL)
-		vBox.PackStart(sep, true, true, 6)
+		packSeparator(vBox)
 
 		for _, item := range config.CustomRows {
-			customRow := setupCustomRow(item.Icon, item.Name, item.Command)
+			customRow := setupCustomRow(item.Icon, item.Name, item.Command, item.Key,
+				confirmMessage(item.Confirm, item.Message, item.Name), item.Actions)
+			rowWidgets[customRowID(item.Name)] = customRow
 			vBox.PackStart(customRow, false, false, 4)
 		}
 	}
 
-	sep, _ := gtk.SeparatorNew(gtk.ORIENTATION_HORIZONTAL)
-	vBox.PackStart(sep, true, true, 6)
+	powerBox = nil
+	if settings.Preferences.ShowPowerButtons {
+		packSeparator(vBox)
+		vBox.PackStart(setupPowerBox(), false, false, 8)
+	}
+
+	packSeparator(vBox)
 
 	buttonBox, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 0)
 
@@ -936,51 +936,343 @@ func main() {
 
 	if settings.Preferences.ShowUserButtons {
 		for _, item := range config.Buttons {
-			customBtn := setupCustomButton(item.Icon, item.Name, item.Command)
+			customBtn := setupCustomButton(item.Icon, item.Name, item.Command, item.Key,
+				confirmMessage(item.Confirm, item.Message, item.Name), item.Actions)
+			rowWidgets[customButtonID(item.Name)] = customBtn
 			buttonBox.PackStart(customBtn, true, false, 4)
 		}
 	}
 
 	vBox.PackStart(buttonBox, false, false, 8)
 
+	confirmBox = setupConfirmBox()
+	vBox.PackStart(confirmBox, false, false, 4)
+
+	return boxOuterV
+}
+
+// Close (or hide, in daemon mode) on Esc key
+func handleKeyboard(window *gtk.Window, event *gdk.Event) {
+	key := &gdk.EventKey{Event: event}
+	if key.KeyVal() == gdk.KEY_Escape {
+		if searchActive() {
+			closeSearch()
+			return
+		}
+		closeWindow()
+	}
+}
+
+func showWindow() {
+	if !win.IsVisible() {
+		updateRows()
+		placeWindow()
+	}
+	win.Present()
+}
+
+// Refreshes rows updated on the fast timer
+func updateFastRows() {
+	if briRow != nil {
+		updateBrightnessRow()
+	}
+	if volRow != nil {
+		updateVolumeRow()
+	}
+	if wifiRow != nil {
+		updateWifiRow()
+	}
+	if interfaceRow != nil {
+		updateInterfaceRow()
+	}
+	if btRow != nil {
+		updateBluetoothRow()
+	}
+}
+
+// Refreshes all the rows
+func updateRows() {
+	if cliLabel != nil {
+		updateCliLabel(*cliLabel)
+	}
+	if batRow != nil {
+		updateBatteryRow()
+	}
+	updateFastRows()
+}
+
+// Hides the window in daemon mode, quits otherwise
+func closeWindow() {
+	if *daemon {
+		closeSearch()
+		cancelConfirmation()
+		win.Hide()
+	} else {
+		gtk.MainQuit()
+	}
+}
+
+func toggleWindow() {
+	if win.IsVisible() {
+		closeWindow()
+	} else {
+		showWindow()
+	}
+}
+
+// Applies changes to preferences and templates: reloads them in daemon mode, quits otherwise
+func applyChanges() {
+	if !*daemon {
+		gtk.MainQuit()
+		return
+	}
+	reload()
+}
+
+// Reloads preferences, templates, cli commands and css, and rebuilds the window content
+func reload() {
+	settings, _ = loadSettings()
+	checkMissingSettings()
+	syncSettingsSnapshot()
+	checkHooks()
+	config, _ = loadConfig()
+	configChanged = false
+	cliCommands = loadCliCommands()
+	desktopEntries = nil
+	setIconsDir()
+	if settings.Preferences.CustomStyling {
+		loadCss()
+	} else if cssProvider != nil {
+		screen, _ := gdk.ScreenGetDefault()
+		gtk.RemoveProviderForScreen(screen, cssProvider)
+		cssProvider = nil
+	}
+	win.SetDecorated(settings.Preferences.WindowDecorations)
+	reloadContent()
+}
+
+// Empty means: gtk icons in use
+func setIconsDir() {
+	iconsDir = ""
+	if settings.Preferences.IconSet == "light" {
+		iconsDir = filepath.Join(dataDir(), "icons_light")
+		fmt.Println("Icons: Custom light")
+	} else if settings.Preferences.IconSet == "dark" {
+		iconsDir = filepath.Join(dataDir(), "icons_dark")
+		fmt.Println("Icons: Custom dark")
+	} else {
+		fmt.Println("Icons: GTK")
+	}
+}
+
+func main() {
+	timeStart := time.Now()
+
+	// Gentle SIGTERM handler thanks to reiki4040 https://gist.github.com/reiki4040/be3705f307d3cd136e85
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, syscall.SIGTERM)
+	go func() {
+		for {
+			s := <-signalChan
+			if s == syscall.SIGTERM {
+				fmt.Println("SIGTERM received, bye bye!")
+				gtk.MainQuit()
+			}
+		}
+	}()
+
+	// Subcommands: they don't need the single instance lock
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "msg":
+			// send a command to the running instance
+			ipcClient(os.Args[2:])
+			os.Exit(0)
+		case "status":
+			statusCommand(os.Args[2:])
+			os.Exit(0)
+		case "osd":
+			osdCommand(os.Args[2:])
+			os.Exit(0)
+		}
+	}
+
+	flag.Parse()
+
+	// Bar output mode runs headless, alongside the GUI instance if any
+	if *barFormat != "" {
+		runBar(*barFormat, flag.Args())
+	}
+
+	// Tray icon needs a process running in the background
+	if *trayMode != "" {
+		if *trayMode != "battery" && *trayMode != "volume" {
+			fmt.Printf("Unknown tray mode '%s', expected 'battery' or 'volume'\n", *trayMode)
+			os.Exit(1)
+		}
+		*daemon = true
+	}
+
+	if *displayVersion {
+		fmt.Printf("nwgocc version %s\n", version)
+		os.Exit(0)
+	}
+
+	// We don't want multiple instances. For better user experience (when nwgocc attached to a button or a key binding),
+	// let's toggle the window of the instance running in daemon mode, or close / replace the running instance.
+	if !*newInstance {
+		lockFile := acquireInstanceLock()
+		defer releaseLock(lockFile)
+	}
+
+	// Use the profile selected last time, unless templates file given
+	if !isFlagSet("c") {
+		profile := loadLastProfile()
+		if profile != "" && findConfigFile(profile) != "" {
+			*configFile = profile
+		}
+	}
+
+	wayland = isWayland()
+	fmt.Printf("Wayland: %t\n", wayland)
+
+	setupDirs()
+
+	if *restoreDefaults || *restoreList != "" {
+		restoreFromCommandLine()
+	}
+
+	// Load Preferences, Icons and Commands from ~/.local/share/nwgocc/preferences.json
+	settings, _ = loadSettings()
+	checkMissingSettings()
+	syncSettingsSnapshot()
+
+	// On `-d` check and print commands availability
+	if *debug {
+		checkCommands(settings.Commands)
+	}
+
+	// Profile selected by rules, unless templates file given
+	if !isFlagSet("c") {
+		ruleProfile, _ = evaluateRules()
+		if ruleProfile != "" && findConfigFile(ruleProfile) != "" {
+			*configFile = ruleProfile
+		}
+	}
+
+	// Load user-defined CustomRows and Buttons from ~/.config/config.json
+	config, _ = loadConfig()
+	fmt.Printf("Templates: '%s'\n", *configFile)
+
+	setIconsDir()
+
+	gtk.Init(nil)
+
+	if settings.Preferences.CustomStyling {
+		loadCss()
+	} else {
+		fmt.Println("Style: GTK")
+	}
+
+	var err error
+	win, err = gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
+	check(err)
+
+	win.SetTitle("nwgocc: Control Center")
+	if !wayland {
+		err = win.SetIconFromFile(filepath.Join(prefix, "share/pixmaps/nwgocc.svg"))
+		if err != nil {
+			win.SetIconName("nwgocc")
+		}
+	}
+	win.SetProperty("name", "window")
+	win.SetDecorated(settings.Preferences.WindowDecorations)
+	if !applyLayerShell() && *winPosPointer {
+		win.SetPosition(gtk.WIN_POS_MOUSE)
+	}
+	win.Connect("destroy", func() {
+		gtk.MainQuit()
+	})
+	// In daemon mode closing the window just hides it
+	win.Connect("delete-event", func() bool {
+		if *daemon {
+			win.Hide()
+			return true
+		}
+		return false
+	})
+
+	// before handleNavigation, which stops keys it handles from going further
+	setupAutoHide()
+	win.Connect("key-release-event", handleKeyboard)
+	win.Connect("key-press-event", handleNavigation)
+
+	cliCommands = loadCliCommands()
+	contentBox = setupContent()
+	win.Add(contentBox)
+
 	win.SetDefaultSize(300, 200)
 
+	// Rows of a hidden window (in daemon mode) get updated when it's shown
 	glib.TimeoutAdd(uint(settings.Preferences.RefreshCliSeconds*1000), func() bool {
-		if cliLabel != nil {
+		if cliLabel != nil && win.IsVisible() {
 			updateCliLabel(*cliLabel)
 		}
 		return true
 	})
 	glib.TimeoutAdd(uint(settings.Preferences.RefreshSlowSeconds*1000), func() bool {
-		if batRow != nil {
+		if batRow != nil && win.IsVisible() {
 			updateBatteryRow()
 		}
+		applyRules()
 		return true
 	})
 	glib.TimeoutAdd(uint(settings.Preferences.RefreshFastMillis), func() bool {
-		if briRow != nil {
-			updateBrightnessRow()
-		}
-
-		if volRow != nil {
-			updateVolumeRow()
+		if win.IsVisible() {
+			updateFastRows()
 		}
+		return true
+	})
 
-		if wifiRow != nil {
-			updateWifiRow()
+	// Additional instances leave the control socket and the D-Bus name to the main one. Both, as well as status
+	// watchers, are only needed when running in the background.
+	if !*newInstance && *daemon {
+		err = startIpcServer()
+		if err == nil {
+			defer stopIpcServer()
+			fmt.Printf("Control socket: '%s'\n", ipcSocketPath())
+		} else {
+			fmt.Println(err)
 		}
 
-		if interfaceRow != nil {
-			updateInterfaceRow()
+		err = startDbusService()
+		if err == nil {
+			fmt.Printf("D-Bus service: '%s'\n", dbusName)
+		} else {
+			fmt.Println("D-Bus service:", err)
 		}
 
-		if btRow != nil {
-			updateBluetoothRow()
+		if *trayMode != "" {
+			err = startTray()
+			if err != nil {
+				fmt.Println("Tray:", err)
+			}
 		}
-		return true
-	})
+		startNotifications()
+		startHooks()
+		statusWatchers = append(statusWatchers, osdOnChanges)
+		watchStatus()
+	}
 
-	win.ShowAll()
+	if *daemon {
+		// Keep the window hidden until toggled; content needs to be shown anyway
+		contentBox.ShowAll()
+		fmt.Println("Daemon mode")
+	} else {
+		placeWindow()
+		win.ShowAll()
+	}
+	applyRules()
 
 	fmt.Printf("Ready in %v ms\n", time.Now().Sub(timeStart).Milliseconds())
 	gtk.Main()
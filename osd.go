@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+var (
+	osdWindow     *gtk.Window
+	osdImage      *gtk.Image
+	osdBar        *gtk.ProgressBar
+	osdTimeout    glib.SourceHandle
+	osdStandalone bool // quit on hide, as there's no main window
+)
+
+// Builds the OSD window: borderless, not taking focus, named "osd" for styling
+func setupOsdWindow() {
+	var err error
+	osdWindow, err = gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
+	check(err)
+	osdWindow.SetProperty("name", "osd")
+	osdWindow.SetTitle("nwgocc: OSD")
+	osdWindow.SetDecorated(false)
+	osdWindow.SetResizable(false)
+	osdWindow.SetTypeHint(gdk.WINDOW_TYPE_HINT_NOTIFICATION)
+	osdWindow.SetAcceptFocus(false)
+	osdWindow.SetKeepAbove(true)
+	osdWindow.SetSkipTaskbarHint(true)
+	osdWindow.SetSkipPagerHint(true)
+	osdWindow.SetPosition(gtk.WIN_POS_CENTER)
+	if settings.Preferences.LayerShell && wayland {
+		setupLayerShell(osdWindow, "bottom", [4]int{0, 0, 100, 0}, false)
+	}
+
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 10)
+	box.SetProperty("margin", 10)
+	osdImage, _ = gtk.ImageNew()
+	box.PackStart(osdImage, false, false, 0)
+	osdBar, _ = gtk.ProgressBarNew()
+	osdBar.SetSizeRequest(160, -1)
+	osdBar.SetVAlign(gtk.ALIGN_CENTER)
+	box.PackStart(osdBar, true, true, 0)
+	osdWindow.Add(box)
+}
+
+// Shows the OSD with the current volume or brightness icon and level, and (re)starts the auto-hide timer.
+// Must be called from the main loop.
+func showOsd(kind string) {
+	icon, level := "", 0
+	switch kind {
+	case "volume":
+		v := getVolumeStatus()
+		icon, level = volumeIcon(v.Volume, v.Muted), v.Volume
+	case "brightness":
+		level = int(getBrightness())
+		icon = brightnessIcon(level)
+	default:
+		return
+	}
+	if osdWindow == nil {
+		setupOsdWindow()
+	}
+	osdImage.SetFromPixbuf(createPixbuf(icon, settings.Preferences.IconSizeLarge))
+	osdBar.SetFraction(float64(level) / 100)
+	osdWindow.ShowAll()
+
+	if osdTimeout != 0 {
+		glib.SourceRemove(osdTimeout)
+	}
+	osdTimeout = glib.TimeoutAdd(uint(settings.Preferences.OsdTimeoutMillis), func() bool {
+		osdTimeout = 0
+		osdWindow.Hide()
+		if osdStandalone {
+			gtk.MainQuit()
+		}
+		return false
+	})
+}
+
+// Adjusts volume or brightness, if value given, and shows the OSD
+func adjustAndShowOsd(kind, value string) error {
+	var err error
+	switch kind {
+	case "volume":
+		if value != "" {
+			err = adjustVolume(value)
+		}
+	case "brightness":
+		if value != "" {
+			err = adjustBrightness(value)
+		}
+	default:
+		return fmt.Errorf("unknown OSD '%s', expected 'volume' or 'brightness'", kind)
+	}
+	if err != nil {
+		return err
+	}
+	showOsd(kind)
+	return nil
+}
+
+// Status watcher showing the OSD on volume or brightness changes, unless the main window is open
+func osdOnChanges(previous, current Status) {
+	if !settings.Preferences.ShowOsd {
+		return
+	}
+	kind := ""
+	if previous.Volume != nil && current.Volume != nil && *previous.Volume != *current.Volume {
+		kind = "volume"
+	} else if previous.Brightness != nil && current.Brightness != nil && *previous.Brightness != *current.Brightness {
+		kind = "brightness"
+	}
+	if kind != "" && !win.IsVisible() {
+		showOsd(kind)
+	}
+}
+
+// Handles `nwgocc osd volume|brightness [value]`: the running instance shows the OSD, if any. Otherwise we show
+// it in a window of our own, and quit when it hides.
+func osdCommand(args []string) {
+	if len(args) == 0 || (args[0] != "volume" && args[0] != "brightness") {
+		fmt.Println("Usage: nwgocc osd volume|brightness [value]")
+		os.Exit(1)
+	}
+	kind, value := args[0], ""
+	if len(args) > 1 {
+		value = args[1]
+	}
+
+	response, err := sendIpcRequest(ipcRequest{Command: "osd", Name: kind, Value: value})
+	if err == nil {
+		if !response.Success {
+			fmt.Println(response.Error)
+			os.Exit(1)
+		}
+		return
+	}
+
+	loadSettingsOrDefaults()
+	wayland = isWayland()
+	setIconsDir()
+	gtk.Init(nil)
+	if settings.Preferences.CustomStyling {
+		loadCss()
+	}
+	osdStandalone = true
+	err = adjustAndShowOsd(kind, value)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	gtk.Main()
+}
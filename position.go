@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gotk3/gotk3/gdk"
+)
+
+// Set if the main window is a layer shell surface
+var layerShellInUse bool
+
+// Anchors available in preferences: none (centered), edges and corners
+var anchors = []string{"", "top-left", "top", "top-right", "left", "right", "bottom-left", "bottom", "bottom-right"}
+
+// Returns which edges (top, right, bottom, left) an anchor like "top-right" sticks the window to
+func anchorEdges(anchor string) [4]bool {
+	var edges [4]bool
+	for _, part := range strings.Split(anchor, "-") {
+		switch part {
+		case "top":
+			edges[0] = true
+		case "right":
+			edges[1] = true
+		case "bottom":
+			edges[2] = true
+		case "left":
+			edges[3] = true
+		}
+	}
+	return edges
+}
+
+// Turns the main window into a layer shell surface, if turned on in preferences and available
+func applyLayerShell() bool {
+	if !settings.Preferences.LayerShell || !wayland {
+		return false
+	}
+	p := settings.Preferences
+	if !setupLayerShell(win, p.Anchor, [4]int{p.MarginTop, p.MarginRight, p.MarginBottom, p.MarginLeft},
+		p.KeyboardExclusive) {
+		fmt.Println("Layer shell: not available")
+		return false
+	}
+	fmt.Printf("Layer shell: anchor '%s'\n", p.Anchor)
+	layerShellInUse = true
+	return true
+}
+
+// Places the layer shell window on the output focused in the compositor (sway, Hyprland), and at the pointer
+// if `-p` given and the compositor tells the pointer position. Must be called before the window gets shown.
+func placeWindow() {
+	if !layerShellInUse {
+		return
+	}
+	out, x, y, pointerKnown := compositorPlacement()
+	if out == nil {
+		return
+	}
+	display, err := gdk.DisplayGetDefault()
+	if err != nil {
+		return
+	}
+	monitor, err := display.GetMonitorAtPoint(out.X+out.Width/2, out.Y+out.Height/2)
+	if err == nil {
+		setLayerShellMonitor(win, monitor)
+	}
+
+	if *winPosPointer && pointerKnown {
+		// keep the window within the output
+		width, height := win.GetSize()
+		left := clamp(x-out.X, 0, out.Width-width)
+		top := clamp(y-out.Y, 0, out.Height-height)
+		setLayerShellPosition(win, "top-left", [4]int{top, 0, 0, left})
+	}
+}
+
+func clamp(value, lower, upper int) int {
+	if value > upper {
+		value = upper
+	}
+	if value < lower {
+		value = lower
+	}
+	return value
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestAnchorEdges(t *testing.T) {
+	tests := []struct {
+		anchor string
+		want   [4]bool // top, right, bottom, left
+	}{
+		{"", [4]bool{}},
+		{"top", [4]bool{true, false, false, false}},
+		{"top-right", [4]bool{true, true, false, false}},
+		{"bottom-left", [4]bool{false, false, true, true}},
+		{"left", [4]bool{false, false, false, true}},
+		{"middle", [4]bool{}},
+	}
+	for _, tt := range tests {
+		if got := anchorEdges(tt.anchor); got != tt.want {
+			t.Errorf("anchorEdges(%q) = %v, want %v", tt.anchor, got, tt.want)
+		}
+	}
+}
+
+func TestClamp(t *testing.T) {
+	tests := []struct {
+		value, lower, upper, want int
+	}{
+		{5, 0, 10, 5},
+		{-5, 0, 10, 0},
+		{15, 0, 10, 10},
+		// window wider than the output: stick to the left edge
+		{5, 0, -20, 0},
+	}
+	for _, tt := range tests {
+		if got := clamp(tt.value, tt.lower, tt.upper); got != tt.want {
+			t.Errorf("clamp(%d, %d, %d) = %d, want %d", tt.value, tt.lower, tt.upper, got, tt.want)
+		}
+	}
+}
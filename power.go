@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// logind D-Bus API, on the system bus
+const (
+	login1Name      = "org.freedesktop.login1"
+	login1Path      = "/org/freedesktop/login1"
+	login1Interface = "org.freedesktop.login1.Manager"
+)
+
+// powerAction is a button of the power section; each one is also a built-in row action, e.g. "@reboot"
+type powerAction struct {
+	action  string
+	label   string
+	icon    string
+	can     string // login1 method telling if the action is available; none for lock and log out
+	method  string // login1 method to call
+	confirm string // confirmation message, if confirmation enabled
+}
+
+var powerActions = []powerAction{
+	{"@lock", "Lock", "system-lock-screen-symbolic", "", "LockSession", ""},
+	{"@logout", "Log out", "system-log-out-symbolic", "", "TerminateSession", "Logging out"},
+	{"@suspend", "Suspend", "weather-clear-night-symbolic", "CanSuspend", "Suspend", ""},
+	{"@hibernate", "Hibernate", "drive-harddisk-symbolic", "CanHibernate", "Hibernate", ""},
+	{"@reboot", "Reboot", "system-reboot-symbolic", "CanReboot", "Reboot", "Rebooting"},
+	{"@poweroff", "Power off", "system-shutdown-symbolic", "CanPowerOff", "PowerOff", "Powering off"},
+}
+
+// Connection to the system bus; replaced in tests
+var systemBus = dbus.SystemBus
+
+// powerSupport is what logind says of power actions; it's on the system bus, which may be slow to answer, so it's
+// asked once per run, in the background
+type powerSupport struct {
+	logind bool
+	can    map[string]bool // by login1 method, e.g. "CanSuspend"
+}
+
+var (
+	powerQuery     sync.Once
+	powerQueried   bool // set in the main loop, when powerSupported is known
+	powerSupported powerSupport
+	powerBox       *gtk.Box // the one in the window, if any
+	powerNavIndex  int      // position of its buttons in navItems
+)
+
+// Calls a login1 Manager method, storing its result (if any) in `result`
+func login1Call(conn *dbus.Conn, method string, result interface{}, args ...interface{}) error {
+	call := conn.Object(login1Name, login1Path).Call(login1Interface+"."+method, 0, args...)
+	if call.Err != nil || result == nil {
+		return call.Err
+	}
+	return call.Store(result)
+}
+
+// Returns true if login1 says the action is available: "yes", or "challenge" (needs authentication)
+func canPower(conn *dbus.Conn, method string) bool {
+	var answer string
+	if err := login1Call(conn, method, &answer); err != nil {
+		fmt.Println("Power:", err)
+		return false
+	}
+	return answer == "yes" || answer == "challenge"
+}
+
+// Asks logind if it's there, and which actions are available; blocks
+func queryPowerSupport(conn *dbus.Conn) powerSupport {
+	support := powerSupport{can: make(map[string]bool)}
+	support.logind = login1Call(conn, "ListSessions", nil) == nil
+	if !support.logind {
+		return support
+	}
+	for _, p := range powerActions {
+		if p.can != "" {
+			support.can[p.can] = canPower(conn, p.can)
+		}
+	}
+	return support
+}
+
+// Returns actions to be shown: lock if there's a command for it, or logind and a locker listening to it; log out
+// if there's a command for it or logind; others if logind says they're supported
+func availablePowerActions(support powerSupport, lockCommand, logoutCommand string, locker bool) []powerAction {
+	var available []powerAction
+	for _, p := range powerActions {
+		switch p.action {
+		case "@lock":
+			if lockCommand != "" || (support.logind && locker) {
+				available = append(available, p)
+			}
+		case "@logout":
+			if logoutCommand != "" || support.logind {
+				available = append(available, p)
+			}
+		default:
+			if support.logind && support.can[p.can] {
+				available = append(available, p)
+			}
+		}
+	}
+	return available
+}
+
+// Tells if the command line is of a program known to lock the screen on logind's Lock signal
+func listensForLock(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	switch filepath.Base(args[0]) {
+	case "hypridle", "xss-lock", "light-locker":
+		return true
+	case "swayidle":
+		// only with a `lock` event
+		for _, arg := range args[1:] {
+			if arg == "lock" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Returns true if a locker listening to logind is running
+func lockerRunning() bool {
+	files, _ := filepath.Glob("/proc/[0-9]*/cmdline")
+	for _, file := range files {
+		cmdline, err := os.ReadFile(file)
+		if err == nil && listensForLock(strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Runs a power action: lock and log out with the commands from preferences if given, otherwise (as well as
+// others) over logind. Closes the window unless told not to.
+func runPowerAction(action string) error {
+	for _, p := range powerActions {
+		if p.action != action {
+			continue
+		}
+		var err error
+		switch {
+		case action == "@lock" && settings.Preferences.LockCommand != "":
+			runShellCommand(settings.Preferences.LockCommand)
+		case action == "@logout" && settings.Preferences.LogoutCommand != "":
+			runShellCommand(settings.Preferences.LogoutCommand)
+		default:
+			var conn *dbus.Conn
+			if conn, err = systemBus(); err != nil {
+				return err
+			}
+			if p.can == "" {
+				// empty session ID stands for the caller's session
+				err = login1Call(conn, p.method, nil, os.Getenv("XDG_SESSION_ID"))
+			} else {
+				// interactive: let polkit ask for a password if needed
+				err = login1Call(conn, p.method, nil, true)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		closeAfterLaunch()
+		return nil
+	}
+	return fmt.Errorf("unknown power action '%s'", action)
+}
+
+// Box of built-in lock / log out / suspend / hibernate / reboot / power off buttons. It stays hidden until logind
+// has been asked which ones are available, the first time in the background.
+func setupPowerBox() *gtk.Box {
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 0)
+	box.SetNoShowAll(true)
+	powerBox = box
+	powerNavIndex = len(navItems)
+	if powerQueried {
+		fillPowerBox(box)
+		return box
+	}
+	powerQuery.Do(func() {
+		go func() {
+			support := powerSupport{}
+			if conn, err := systemBus(); err == nil {
+				support = queryPowerSupport(conn)
+			} else {
+				fmt.Println("Power:", err)
+			}
+			glib.IdleAdd(func() {
+				powerSupported, powerQueried = support, true
+				if powerBox != nil {
+					fillPowerBox(powerBox)
+					applyRules()
+				}
+			})
+		}()
+	})
+	return box
+}
+
+// Adds buttons of the available actions to the box, and shows it if there are any
+func fillPowerBox(box *gtk.Box) {
+	available := availablePowerActions(powerSupported, settings.Preferences.LockCommand,
+		settings.Preferences.LogoutCommand, lockerRunning())
+	if len(available) == 0 {
+		return
+	}
+	added := len(navItems)
+	for _, p := range available {
+		p := p
+		button, _ := gtk.ButtonNew()
+		if settings.Preferences.CustomStyling {
+			button.SetProperty("name", "custom-button")
+		}
+		pixbuf := createPixbuf(p.icon, settings.Preferences.IconSizeLarge)
+		image, _ := gtk.ImageNewFromPixbuf(pixbuf)
+		button.SetImage(image)
+		button.SetAlwaysShowImage(true)
+		button.SetTooltipText(p.label)
+		button.Connect("clicked", func() {
+			if settings.Preferences.ConfirmPower && p.confirm != "" {
+				askConfirmation(p.confirm, p.action)
+			} else {
+				runAction(p.action)
+			}
+		})
+		addNavItem(p.label, "", &button.Widget, button.Clicked, nil)
+		box.PackStart(button, true, false, 4)
+		button.ShowAll()
+	}
+	// keep the window order, if buttons below got added in the meantime
+	items := append([]*navItem{}, navItems[:powerNavIndex]...)
+	items = append(items, navItems[added:]...)
+	navItems = append(items, navItems[powerNavIndex:added]...)
+
+	rowWidgets[rowPower] = box
+	box.Show()
+}
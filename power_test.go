@@ -0,0 +1,156 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// mockLogin1 answers the login1 Manager methods we use, recording calls of these that act
+type mockLogin1 struct {
+	calls *[]string
+}
+
+type login1Session struct {
+	ID   string
+	UID  uint32
+	User string
+	Seat string
+	Path dbus.ObjectPath
+}
+
+func (m mockLogin1) ListSessions() ([]login1Session, *dbus.Error) {
+	return []login1Session{{"3", 1000, "user", "seat0", "/org/freedesktop/login1/session/_33"}}, nil
+}
+
+func (m mockLogin1) CanSuspend() (string, *dbus.Error)   { return "yes", nil }
+func (m mockLogin1) CanHibernate() (string, *dbus.Error) { return "na", nil }
+func (m mockLogin1) CanReboot() (string, *dbus.Error)    { return "challenge", nil }
+func (m mockLogin1) CanPowerOff() (string, *dbus.Error)  { return "no", nil }
+
+func (m mockLogin1) Reboot(interactive bool) *dbus.Error {
+	*m.calls = append(*m.calls, "Reboot")
+	return nil
+}
+
+func (m mockLogin1) LockSession(id string) *dbus.Error {
+	*m.calls = append(*m.calls, "LockSession "+id)
+	return nil
+}
+
+func TestLogin1(t *testing.T) {
+	address := privateBus(t)
+	service, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer service.Close()
+	var calls []string
+	if err := service.Export(mockLogin1{&calls}, login1Path, login1Interface); err != nil {
+		t.Fatal(err)
+	}
+	if reply, err := service.RequestName(login1Name, dbus.NameFlagDoNotQueue); err != nil ||
+		reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("can't own %s: %v", login1Name, err)
+	}
+
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	want := powerSupport{logind: true, can: map[string]bool{
+		"CanSuspend": true, "CanHibernate": false, "CanReboot": true, "CanPowerOff": false}}
+	if got := queryPowerSupport(conn); !reflect.DeepEqual(got, want) {
+		t.Errorf("queryPowerSupport: got %+v, want %+v", got, want)
+	}
+
+	bus := systemBus
+	dontClose, lockCommand := settings.Preferences.DontClose, settings.Preferences.LockCommand
+	defer func() {
+		systemBus = bus
+		settings.Preferences.DontClose, settings.Preferences.LockCommand = dontClose, lockCommand
+	}()
+	systemBus = func() (*dbus.Conn, error) {
+		return conn, nil
+	}
+	settings.Preferences.DontClose = true
+	settings.Preferences.LockCommand = ""
+	t.Setenv("XDG_SESSION_ID", "3")
+
+	for _, action := range []string{"@reboot", "@lock"} {
+		if err := runPowerAction(action); err != nil {
+			t.Errorf("%s: %v", action, err)
+		}
+	}
+	if err := runPowerAction("@poweroff"); err == nil {
+		t.Error("@poweroff: no error from a method not there")
+	}
+	if err := runPowerAction("@bogus"); err == nil {
+		t.Error("@bogus: no error")
+	}
+	if want := []string{"Reboot", "LockSession 3"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls: got %v, want %v", calls, want)
+	}
+}
+
+func TestLogin1Missing(t *testing.T) {
+	conn, err := dbus.Connect(privateBus(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if got := queryPowerSupport(conn); got.logind || len(got.can) != 0 {
+		t.Errorf("got %+v, want no logind", got)
+	}
+}
+
+func TestAvailablePowerActions(t *testing.T) {
+	logind := powerSupport{logind: true, can: map[string]bool{"CanSuspend": true, "CanReboot": true}}
+	tests := []struct {
+		name          string
+		support       powerSupport
+		lockCommand   string
+		logoutCommand string
+		locker        bool
+		want          []string
+	}{
+		{"logind, no locker", logind, "", "", false, []string{"@logout", "@suspend", "@reboot"}},
+		{"logind and locker", logind, "", "", true, []string{"@lock", "@logout", "@suspend", "@reboot"}},
+		{"lock command", logind, "swaylock", "", false, []string{"@lock", "@logout", "@suspend", "@reboot"}},
+		{"no logind", powerSupport{}, "", "", true, nil},
+		{"no logind, commands", powerSupport{}, "swaylock", "swaymsg exit", false, []string{"@lock", "@logout"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, p := range availablePowerActions(tt.support, tt.lockCommand, tt.logoutCommand, tt.locker) {
+				got = append(got, p.action)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListensForLock(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"swayidle", "-w", "timeout", "300", "swaylock -f", "lock", "swaylock -f"}, true},
+		{[]string{"/usr/bin/swayidle", "-w", "timeout", "300", "swaylock -f"}, false},
+		{[]string{"/usr/bin/hypridle"}, true},
+		{[]string{"xss-lock", "--", "i3lock"}, true},
+		{[]string{"swaylock"}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := listensForLock(tt.args); got != tt.want {
+			t.Errorf("listensForLock(%q) = %v; want %v", tt.args, got, tt.want)
+		}
+	}
+}
@@ -2,9 +2,8 @@ package main
 
 import (
 	"errors"
-	"fmt"
 	"log"
-	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/gotk3/gotk3/gdk"
@@ -16,26 +15,19 @@ var prefWindow *gtk.Window
 var cliTextView *gtk.TextView
 
 func setupPreferencesWindow() {
-	builder, err := gtk.BuilderNewFromFile("/usr/share/nwgocc/preferences.glade")
+	glade, err := readAsset("preferences.glade")
+	check(err)
+	builder, err := gtk.BuilderNewFromString(string(glade))
 	check(err)
 
 	obj, err := builder.GetObject("preferences_window")
 	check(err)
 
 	if settings.Preferences.CustomStyling {
-		css := filepath.Join(configDir(), *cssFile)
-		fmt.Printf("Style: %s\n", css)
-		cssProvider, err := gtk.CssProviderNew()
-		check(err)
-		err = cssProvider.LoadFromPath(css)
-		if err != nil {
-			fmt.Println(err)
-		}
-		screen, _ := gdk.ScreenGetDefault()
-		gtk.AddProviderForScreen(screen, cssProvider, gtk.STYLE_PROVIDER_PRIORITY_USER)
+		loadCss()
 	}
 
-	prefWindow, err := isWindow(obj)
+	prefWindow, err = isWindow(obj)
 	check(err)
 
 	// TextView to edit CLI Label command(s)
@@ -97,28 +89,6 @@ func setupPreferencesWindow() {
 		settings.Preferences.ShowInterfaceLine = cbNetInterface.GetActive()
 	})
 
-	// Buttons to edit user-defined commands assigned to built-in rows
-	btnUser := setButtonImage(builder, "cmd_btn_user", settings.Icons.ClickMe)
-	btnUser.Connect("clicked", func() {
-		setupCmdDialog(&settings.Preferences.OnClickUser)
-	})
-	btnWifi := setButtonImage(builder, "cmd_btn_wifi", settings.Icons.ClickMe)
-	btnWifi.Connect("clicked", func() {
-		setupCmdDialog(&settings.Preferences.OnClickWifi)
-	})
-	btnBt := setButtonImage(builder, "cmd_btn_bt", settings.Icons.ClickMe)
-	btnBt.Connect("clicked", func() {
-		setupCmdDialog(&settings.Preferences.OnClickBluetooth)
-	})
-	btnBattery := setButtonImage(builder, "cmd_btn_battery", settings.Icons.ClickMe)
-	btnBattery.Connect("clicked", func() {
-		setupCmdDialog(&settings.Preferences.OnClickBattery)
-	})
-	btnInterface := setButtonImage(builder, "cmd_btn_interface", settings.Icons.ClickMe)
-	btnInterface.Connect("clicked", func() {
-		setupCmdDialog(&settings.Preferences.OnClickInterface)
-	})
-
 	// Lower checkboxes for various boolean settings
 	cbCustomStyling := setUpCheckButton(builder, "checkbutton_custom_css", settings.Preferences.CustomStyling)
 	cbCustomStyling.Connect("toggled", func() {
@@ -141,6 +111,17 @@ func setupPreferencesWindow() {
 		settings.Preferences.InterfaceName = cBoxNetInterface.GetActiveID()
 	})
 
+	// ComboBox to switch profile (templates file)
+	cbProfile := setUpProfileCombo(builder, "combo_box_profile")
+	cbProfile.Connect("changed", func() {
+		switchProfile(cbProfile.GetActiveID())
+	})
+
+	cbProfileSelector := setUpCheckButton(builder, "checkbutton_profile_selector", settings.Preferences.ShowProfileSelector)
+	cbProfileSelector.Connect("toggled", func() {
+		settings.Preferences.ShowProfileSelector = cbProfileSelector.GetActive()
+	})
+
 	// ComboBox to select active icon set
 	cbIconsSet := setUpIconsSetCombo(builder, "combo_box_icons")
 	cbIconsSet.Connect("changed", func() {
@@ -160,23 +141,106 @@ func setupPreferencesWindow() {
 	})
 
 	sbRefreshCli := setUpSpinbutton(builder, "spinbutton_refresh_cli",
-		settings.Preferences.RefreshCliSeconds, 0, 3600)
+		settings.Preferences.RefreshCliSeconds, 1, 3600)
 	sbRefreshCli.Connect("value-changed", func() {
 		settings.Preferences.RefreshCliSeconds = int(sbRefreshCli.GetValue())
 	})
 
 	sbRefreshFastMillis := setUpSpinbutton(builder, "spinbutton_refresh_sliders",
-		settings.Preferences.RefreshFastMillis, 0, 1000)
+		settings.Preferences.RefreshFastMillis, 100, 1000)
 	sbRefreshFastMillis.Connect("value-changed", func() {
 		settings.Preferences.RefreshFastMillis = int(sbRefreshFastMillis.GetValue())
 	})
 
 	sbRefreshSlowSeconds := setUpSpinbutton(builder, "spinbutton_refresh_battery",
-		settings.Preferences.RefreshSlowSeconds, 0, 60)
+		settings.Preferences.RefreshSlowSeconds, 1, 60)
 	sbRefreshSlowSeconds.Connect("value-changed", func() {
 		settings.Preferences.RefreshSlowSeconds = int(sbRefreshSlowSeconds.GetValue())
 	})
 
+	sbOsdTimeout := setUpSpinbutton(builder, "spinbutton_osd_timeout",
+		settings.Preferences.OsdTimeoutMillis, 100, 10000)
+	sbOsdTimeout.Connect("value-changed", func() {
+		settings.Preferences.OsdTimeoutMillis = int(sbOsdTimeout.GetValue())
+	})
+
+	cbOsd := setUpCheckButton(builder, "checkbutton_osd", settings.Preferences.ShowOsd)
+	cbOsd.Connect("toggled", func() {
+		settings.Preferences.ShowOsd = cbOsd.GetActive()
+	})
+
+	sbAutoHide := setUpSpinbutton(builder, "spinbutton_auto_hide",
+		settings.Preferences.AutoHideSeconds, 0, 3600)
+	sbAutoHide.Connect("value-changed", func() {
+		settings.Preferences.AutoHideSeconds = int(sbAutoHide.GetValue())
+	})
+
+	cbFocusOut := setUpCheckButton(builder, "checkbutton_focus_out", settings.Preferences.CloseOnFocusOut)
+	cbFocusOut.Connect("toggled", func() {
+		settings.Preferences.CloseOnFocusOut = cbFocusOut.GetActive()
+	})
+
+	cbSearchApps := setUpCheckButton(builder, "checkbutton_search_apps", settings.Preferences.SearchDesktopApps)
+	cbSearchApps.Connect("toggled", func() {
+		settings.Preferences.SearchDesktopApps = cbSearchApps.GetActive()
+	})
+
+	sbConfirm := setUpSpinbutton(builder, "spinbutton_confirm", settings.Preferences.ConfirmSeconds, 0, 60)
+	sbConfirm.Connect("value-changed", func() {
+		settings.Preferences.ConfirmSeconds = int(sbConfirm.GetValue())
+	})
+
+	// Power section
+	cbPower := setUpCheckButton(builder, "checkbutton_power", settings.Preferences.ShowPowerButtons)
+	cbPower.Connect("toggled", func() {
+		settings.Preferences.ShowPowerButtons = cbPower.GetActive()
+	})
+
+	cbConfirmPower := setUpCheckButton(builder, "checkbutton_confirm_power", settings.Preferences.ConfirmPower)
+	cbConfirmPower.Connect("toggled", func() {
+		settings.Preferences.ConfirmPower = cbConfirmPower.GetActive()
+	})
+
+	entryLock := setUpEntry(builder, "entry_lock_command", settings.Preferences.LockCommand)
+	entryLock.Connect("changed", func() {
+		settings.Preferences.LockCommand, _ = entryLock.GetText()
+	})
+
+	entryLogout := setUpEntry(builder, "entry_logout_command", settings.Preferences.LogoutCommand)
+	entryLogout.Connect("changed", func() {
+		settings.Preferences.LogoutCommand, _ = entryLogout.GetText()
+	})
+
+	// Layer shell position
+	cbAnchor := setUpAnchorCombo(builder, "combo_box_anchor")
+	cbAnchor.Connect("changed", func() {
+		settings.Preferences.Anchor = cbAnchor.GetActiveID()
+	})
+
+	cbLayerShell := setUpCheckButton(builder, "checkbutton_layer_shell", settings.Preferences.LayerShell)
+	cbLayerShell.Connect("toggled", func() {
+		settings.Preferences.LayerShell = cbLayerShell.GetActive()
+	})
+
+	margins := map[string]*int{
+		"spinbutton_margin_top":    &settings.Preferences.MarginTop,
+		"spinbutton_margin_right":  &settings.Preferences.MarginRight,
+		"spinbutton_margin_bottom": &settings.Preferences.MarginBottom,
+		"spinbutton_margin_left":   &settings.Preferences.MarginLeft,
+	}
+	for id, margin := range margins {
+		margin := margin
+		sb := setUpSpinbutton(builder, id, *margin, 0, 1000)
+		sb.Connect("value-changed", func() {
+			*margin = int(sb.GetValue())
+		})
+	}
+
+	cbKeyboard := setUpCheckButton(builder, "checkbutton_keyboard_exclusive", settings.Preferences.KeyboardExclusive)
+	cbKeyboard.Connect("toggled", func() {
+		settings.Preferences.KeyboardExclusive = cbKeyboard.GetActive()
+	})
+
 	// bottom Buttons
 	btnUserRows := getButtonFromBuilder(builder, "btn_user_rows")
 	btnUserRows.Connect("clicked", func() {
@@ -193,21 +257,43 @@ func setupPreferencesWindow() {
 		setupIconsEditionWindow()
 	})
 
+	btnActions := getButtonFromBuilder(builder, "btn_actions")
+	btnActions.Connect("clicked", func() {
+		setupActionsEditionWindow()
+	})
+
+	btnReset := getButtonFromBuilder(builder, "btn_reset")
+	btnReset.Connect("clicked", func() {
+		menu := setupResetMenu()
+		menu.PopupAtWidget(btnReset, gdk.GDK_GRAVITY_NORTH_WEST, gdk.GDK_GRAVITY_SOUTH_WEST, nil)
+	})
+
 	btnCancel := getButtonFromBuilder(builder, "btn_cancel")
 	btnCancel.Connect("clicked", func() {
 		prefWindow.Close()
 	})
 
+	// widgets edit settings and templates in place: closing without Apply brings back these on disk
+	applied := false
+	prefWindow.Connect("destroy", func() {
+		if !applied {
+			discardPreferences()
+		}
+	})
+
 	btnApply := getButtonFromBuilder(builder, "btn_apply")
 	btnApply.Connect("clicked", func() {
+		applied = true
 		saveCliCommands()
 		err := saveSettings()
 		check(err)
 		if configChanged {
-			err := saveConfig()
-			check(err)
+			if err := saveConfig(); err != nil {
+				log.Println(err)
+			}
 		}
-		gtk.MainQuit()
+		prefWindow.Close()
+		applyChanges()
 	})
 
 	prefWindow.SetTransientFor(win)
@@ -216,6 +302,15 @@ func setupPreferencesWindow() {
 	prefWindow.Connect("key-release-event", handleEscape)
 }
 
+// Drops changes made in the Preferences window, loading settings and templates from disk again
+func discardPreferences() {
+	loadSettingsOrDefaults()
+	if configChanged {
+		config, _ = loadConfig()
+		configChanged = false
+	}
+}
+
 func isWindow(obj glib.IObject) (*gtk.Window, error) {
 	if win, ok := obj.(*gtk.Window); ok {
 		return win, nil
@@ -251,18 +346,15 @@ func setUpSpinbutton(builder *gtk.Builder, id string, value int, min, max float6
 	return nil
 }
 
-func setButtonImage(builder *gtk.Builder, id string, icon string) *gtk.Button {
+func setUpEntry(builder *gtk.Builder, id string, text string) *gtk.Entry {
 	obj, err := builder.GetObject(id)
 	if err != nil {
 		log.Println(err)
 		return nil
 	}
-	if btn, ok := obj.(*gtk.Button); ok {
-		pixbuf := createPixbuf(icon, settings.Preferences.IconSizeSmall)
-		image, err := gtk.ImageNewFromPixbuf(pixbuf)
-		check(err)
-		btn.SetImage(image)
-		return btn
+	if entry, ok := obj.(*gtk.Entry); ok {
+		entry.SetText(text)
+		return entry
 	}
 	return nil
 }
@@ -297,6 +389,22 @@ func setUpNetInterfaceCombo(builder *gtk.Builder, id string) *gtk.ComboBoxText {
 	return nil
 }
 
+func setUpProfileCombo(builder *gtk.Builder, id string) *gtk.ComboBoxText {
+	obj, err := builder.GetObject(id)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	if cb, ok := obj.(*gtk.ComboBoxText); ok {
+		for _, profile := range listProfiles() {
+			cb.Append(profile, strings.TrimSuffix(profile, ".json"))
+		}
+		cb.SetActiveID(*configFile)
+		return cb
+	}
+	return nil
+}
+
 func setUpIconsSetCombo(builder *gtk.Builder, id string) *gtk.ComboBoxText {
 	obj, err := builder.GetObject(id)
 	if err != nil {
@@ -310,6 +418,26 @@ func setUpIconsSetCombo(builder *gtk.Builder, id string) *gtk.ComboBoxText {
 	return nil
 }
 
+func setUpAnchorCombo(builder *gtk.Builder, id string) *gtk.ComboBoxText {
+	obj, err := builder.GetObject(id)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	if cb, ok := obj.(*gtk.ComboBoxText); ok {
+		for _, anchor := range anchors {
+			if anchor == "" {
+				cb.Append(anchor, "none (center)")
+			} else {
+				cb.Append(anchor, anchor)
+			}
+		}
+		cb.SetActiveID(settings.Preferences.Anchor)
+		return cb
+	}
+	return nil
+}
+
 func getButtonFromBuilder(builder *gtk.Builder, id string) *gtk.Button {
 	obj, err := builder.GetObject(id)
 	if err != nil {
@@ -338,44 +466,77 @@ func saveCliCommands() {
 	saveCliFile(s)
 }
 
-func setupCmdDialog(command *string) {
+// Menu to choose components to restore to defaults
+func setupResetMenu() *gtk.Menu {
+	menu, _ := gtk.MenuNew()
+	for _, component := range append(restoreComponents, "all") {
+		c := component
+		item, _ := gtk.MenuItemNewWithLabel(strings.ToUpper(c[:1]) + c[1:])
+		item.Connect("activate", func() {
+			components, _ := parseComponents(c)
+			setupResetDialog(components)
+		})
+		menu.Append(item)
+	}
+	menu.ShowAll()
+
+	return menu
+}
+
+// Shows changes restoring defaults would make, and restores them on confirmation
+func setupResetDialog(components []string) {
 	win, err := gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
 	check(err)
 
 	win.SetTransientFor(prefWindow)
 	win.SetModal(true)
 	win.SetKeepAbove(true)
-	win.SetDecorated(false)
 	win.SetTypeHint(gdk.WINDOW_TYPE_HINT_DIALOG)
-	win.SetTitle("nwgocc: Edit command")
+	win.SetTitle("nwgocc: Reset to defaults")
 	win.SetProperty("name", "preferences")
+	win.SetDefaultSize(600, 400)
 	win.Connect("key-release-event", handleEscape)
 
 	vbox, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 6)
-	hbox, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 0)
-	vbox.PackStart(hbox, true, true, 6)
 	win.Add(vbox)
 
-	entry, _ := gtk.EntryNew()
-	entry.SetProperty("name", "edit-field")
-	entry.SetWidthChars(25)
-	entry.SetText(*command)
-	hbox.PackStart(entry, true, true, 3)
+	diff := restoreDiff(components)
+	if diff == "" {
+		diff = "Nothing to restore: files match defaults"
+	}
+	scrolledWindow, _ := gtk.ScrolledWindowNew(nil, nil)
+	scrolledWindow.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_AUTOMATIC)
+	textView, _ := gtk.TextViewNew()
+	textView.SetEditable(false)
+	textView.SetMonospace(true)
+	buffer, _ := textView.GetBuffer()
+	buffer.SetText(diff)
+	scrolledWindow.Add(textView)
+	vbox.PackStart(scrolledWindow, true, true, 6)
 
-	btnCancel, _ := gtk.ButtonNew()
-	btnCancel.SetLabel("Cancel")
-	btnCancel.Connect("clicked", func() {
-		win.Close()
-	})
-	hbox.PackStart(btnCancel, false, false, 3)
+	hbox, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 0)
+	vbox.PackStart(hbox, false, false, 6)
+
+	label, _ := gtk.LabelNew("Modified files will be backed up")
+	hbox.PackStart(label, false, false, 6)
 
 	btnApply, _ := gtk.ButtonNew()
-	btnApply.SetLabel("Apply")
+	btnApply.SetLabel("Reset")
 	btnApply.Connect("clicked", func() {
-		*command, _ = entry.GetText()
+		err := restore(components)
+		check(err)
+		win.Close()
+		prefWindow.Close()
+		applyChanges()
+	})
+	hbox.PackEnd(btnApply, false, false, 3)
+
+	btnCancel, _ := gtk.ButtonNew()
+	btnCancel.SetLabel("Cancel")
+	btnCancel.Connect("clicked", func() {
 		win.Close()
 	})
-	hbox.PackStart(btnApply, false, false, 3)
+	hbox.PackEnd(btnCancel, false, false, 3)
 
 	win.ShowAll()
 }
@@ -443,9 +604,14 @@ func setupTemplateEditionWindow(definitions interface{}) {
 			fcButton := setupFCButton(iconEntry)
 			grid.Attach(fcButton, 3, i+1, 1, 1)
 
-			cb, _ := gtk.CheckButtonNewWithLabel("Delete")
+			cb, _ := gtk.CheckButtonNewWithLabel("Confirm")
+			cb.SetActive(d.Confirm)
+			cb.SetTooltipText("Ask before running the command")
 			grid.Attach(cb, 4, i+1, 1, 1)
 
+			cb, _ = gtk.CheckButtonNewWithLabel("Delete")
+			grid.Attach(cb, 5, i+1, 1, 1)
+
 			lastRow++
 		}
 	case *[]Button:
@@ -477,9 +643,14 @@ func setupTemplateEditionWindow(definitions interface{}) {
 			fcButton := setupFCButton(iconEntry)
 			grid.Attach(fcButton, 3, i+1, 1, 1)
 
-			cb, _ := gtk.CheckButtonNewWithLabel("Delete")
+			cb, _ := gtk.CheckButtonNewWithLabel("Confirm")
+			cb.SetActive(d.Confirm)
+			cb.SetTooltipText("Ask before running the command")
 			grid.Attach(cb, 4, i+1, 1, 1)
 
+			cb, _ = gtk.CheckButtonNewWithLabel("Delete")
+			grid.Attach(cb, 5, i+1, 1, 1)
+
 			lastRow++
 		}
 	default:
@@ -511,7 +682,27 @@ func setupTemplateEditionWindow(definitions interface{}) {
 	fcButton := setupFCButton(iconEntry)
 	grid.Attach(fcButton, 3, lastRow+1, 1, 1)
 
+	cb, _ := gtk.CheckButtonNewWithLabel("Confirm")
+	cb.SetTooltipText("Ask before running the command")
+	grid.Attach(cb, 4, lastRow+1, 1, 1)
+
+	// Pre-fills the new entry from a .desktop file; the reference is kept unless the command or icon gets edited
+	var chosenID, chosenCommand, chosenIcon string
 	btn, _ := gtk.ButtonNew()
+	btn.SetLabel("Add from application…")
+	btn.Connect("clicked", func() {
+		setupApplicationChooser(win, func(app desktopEntry) {
+			chosenID, chosenCommand, chosenIcon = app.ID, desktopCommand(app.Exec), app.Icon
+			field, _ := grid.GetChildAt(0, lastRow+1)
+			field.(*gtk.Entry).SetText(app.Name)
+			field, _ = grid.GetChildAt(1, lastRow+1)
+			field.(*gtk.Entry).SetText(chosenCommand)
+			iconEntry.SetText(app.Icon)
+		})
+	})
+	grid.Attach(btn, 0, lastRow+2, 1, 1)
+
+	btn, _ = gtk.ButtonNew()
 	btn.SetLabel("Cancel")
 	btn.Connect("clicked", func() {
 		win.Close()
@@ -525,23 +716,33 @@ func setupTemplateEditionWindow(definitions interface{}) {
 	case *[]CustomRow:
 		btn.Connect("clicked", func() {
 			var cRows []CustomRow
-			var cRow CustomRow
 			for row := 1; row < lastRow+1; row++ {
-				field, _ := grid.GetChildAt(4, row)
+				field, _ := grid.GetChildAt(5, row)
 				delete := field.(*gtk.CheckButton).GetActive()
 				if !delete {
+					// start from the original entry, to keep fields not shown in the editor
+					cRow := (*definitions.(*[]CustomRow))[row-1]
 					field, _ := grid.GetChildAt(0, row)
 					text, _ := field.(*gtk.Entry).GetText()
 					cRow.Name = text
 
 					field, _ = grid.GetChildAt(1, row)
 					text, _ = field.(*gtk.Entry).GetText()
+					if text != cRow.Command {
+						cRow.DesktopID = ""
+					}
 					cRow.Command = text
 
 					field, _ = grid.GetChildAt(2, row)
 					text, _ = field.(*gtk.Entry).GetText()
+					if text != cRow.Icon {
+						cRow.DesktopID = ""
+					}
 					cRow.Icon = text
 
+					field, _ = grid.GetChildAt(4, row)
+					cRow.Confirm = field.(*gtk.CheckButton).GetActive()
+
 					cRows = append(cRows, cRow)
 				}
 			}
@@ -559,6 +760,12 @@ func setupTemplateEditionWindow(definitions interface{}) {
 				field, _ = grid.GetChildAt(2, lastRow+1)
 				text, _ = field.(*gtk.Entry).GetText()
 				newRow.Icon = text
+				if chosenID != "" && newRow.Command == chosenCommand && newRow.Icon == chosenIcon {
+					newRow.DesktopID = chosenID
+				}
+
+				field, _ = grid.GetChildAt(4, lastRow+1)
+				newRow.Confirm = field.(*gtk.CheckButton).GetActive()
 
 				cRows = append(cRows, newRow)
 			}
@@ -570,23 +777,32 @@ func setupTemplateEditionWindow(definitions interface{}) {
 	case *[]Button:
 		btn.Connect("clicked", func() {
 			var cBtns []Button
-			var cBtn Button
 			for row := 1; row < lastRow+1; row++ {
-				field, _ := grid.GetChildAt(4, row)
+				field, _ := grid.GetChildAt(5, row)
 				delete := field.(*gtk.CheckButton).GetActive()
 				if !delete {
+					cBtn := (*definitions.(*[]Button))[row-1]
 					field, _ := grid.GetChildAt(0, row)
 					text, _ := field.(*gtk.Entry).GetText()
 					cBtn.Name = text
 
 					field, _ = grid.GetChildAt(1, row)
 					text, _ = field.(*gtk.Entry).GetText()
+					if text != cBtn.Command {
+						cBtn.DesktopID = ""
+					}
 					cBtn.Command = text
 
 					field, _ = grid.GetChildAt(2, row)
 					text, _ = field.(*gtk.Entry).GetText()
+					if text != cBtn.Icon {
+						cBtn.DesktopID = ""
+					}
 					cBtn.Icon = text
 
+					field, _ = grid.GetChildAt(4, row)
+					cBtn.Confirm = field.(*gtk.CheckButton).GetActive()
+
 					cBtns = append(cBtns, cBtn)
 				}
 			}
@@ -604,6 +820,12 @@ func setupTemplateEditionWindow(definitions interface{}) {
 				field, _ = grid.GetChildAt(2, lastRow+1)
 				text, _ = field.(*gtk.Entry).GetText()
 				newBtn.Icon = text
+				if chosenID != "" && newBtn.Command == chosenCommand && newBtn.Icon == chosenIcon {
+					newBtn.DesktopID = chosenID
+				}
+
+				field, _ = grid.GetChildAt(4, lastRow+1)
+				newBtn.Confirm = field.(*gtk.CheckButton).GetActive()
 
 				cBtns = append(cBtns, newBtn)
 			}
@@ -627,6 +849,75 @@ func setupTemplateEditionWindow(definitions interface{}) {
 	win.ShowAll()
 }
 
+// Lets the user pick one of installed applications, filtered by name as they type
+func setupApplicationChooser(parent *gtk.Window, onChosen func(desktopEntry)) {
+	win, _ := gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
+	win.SetTitle("nwgocc: Choose Application")
+	win.SetTransientFor(parent)
+	win.SetModal(true)
+	win.SetKeepAbove(true)
+	win.SetTypeHint(gdk.WINDOW_TYPE_HINT_DIALOG)
+	win.SetProperty("name", "preferences")
+	win.Connect("key-release-event", handleEscape)
+
+	vbox, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 6)
+	vbox.SetProperty("margin", 10)
+
+	searchEntry, _ := gtk.SearchEntryNew()
+	vbox.PackStart(searchEntry, false, false, 0)
+
+	scrolled, _ := gtk.ScrolledWindowNew(nil, nil)
+	scrolled.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
+	scrolled.SetSizeRequest(400, 400)
+	vbox.PackStart(scrolled, true, true, 0)
+
+	list, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 0)
+	scrolled.Add(list)
+
+	apps := listDesktopEntries()
+	sort.Slice(apps, func(i, j int) bool {
+		return strings.ToLower(apps[i].Name) < strings.ToLower(apps[j].Name)
+	})
+
+	fill := func(phrase string) {
+		children := list.GetChildren()
+		children.Foreach(func(item interface{}) {
+			item.(*gtk.Widget).Destroy()
+		})
+		for _, app := range apps {
+			if phrase != "" && fuzzyScore(phrase, app.Name) == 0 {
+				continue
+			}
+			app := app
+			button, _ := gtk.ButtonNew()
+			button.SetRelief(gtk.RELIEF_NONE)
+			hbox, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 10)
+			image, _ := gtk.ImageNewFromPixbuf(createPixbuf(app.Icon, settings.Preferences.IconSizeSmall))
+			hbox.PackStart(image, false, false, 0)
+			label, _ := gtk.LabelNew(app.Name)
+			hbox.PackStart(label, false, false, 0)
+			button.Add(hbox)
+			if app.Comment != "" {
+				button.SetTooltipText(app.Comment)
+			}
+			button.Connect("clicked", func() {
+				onChosen(app)
+				win.Close()
+			})
+			list.PackStart(button, false, false, 0)
+		}
+		list.ShowAll()
+	}
+	searchEntry.Connect("search-changed", func() {
+		text, _ := searchEntry.GetText()
+		fill(text)
+	})
+	fill("")
+
+	win.Add(vbox)
+	win.ShowAll()
+}
+
 func setupFCButton(entry *gtk.Entry) *gtk.Button {
 	btn, _ := gtk.ButtonNew()
 	imgOpen, _ := gtk.ImageNewFromPixbuf(createPixbuf("document-open-symbolic", settings.Preferences.IconSizeSmall))
@@ -654,6 +945,129 @@ func setupFCButton(entry *gtk.Entry) *gtk.Button {
 	return btn
 }
 
+// Edits actions of rows by trigger: one row at a time, chosen from built-in rows, user rows and buttons.
+// Empty fields mean the built-in action (shown as placeholder), or none.
+func setupActionsEditionWindow() {
+	win, _ := gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
+
+	win.SetTransientFor(prefWindow)
+	win.SetModal(true)
+	win.SetKeepAbove(true)
+	win.SetTypeHint(gdk.WINDOW_TYPE_HINT_DIALOG)
+	win.SetTitle("nwgocc: Edit Actions")
+	win.SetProperty("name", "preferences")
+	win.Connect("key-release-event", handleEscape)
+
+	vbox, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 6)
+	hbox, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 0)
+	vbox.PackStart(hbox, true, true, 20)
+
+	grid, _ := gtk.GridNew()
+	grid.SetColumnSpacing(10)
+	grid.SetRowSpacing(10)
+	hbox.PackStart(grid, true, true, 20)
+
+	// working copy, so that Cancel discards changes
+	actions := make(map[string]map[string]string)
+	for id, triggers := range settings.Preferences.Actions {
+		actions[id] = make(map[string]string)
+		for trigger, action := range triggers {
+			actions[id][trigger] = action
+		}
+	}
+
+	ids := append([]string{}, builtInRows...)
+	commands := make(map[string]string)
+	for _, row := range config.CustomRows {
+		ids = append(ids, customRowID(row.Name))
+		commands[customRowID(row.Name)] = row.Command
+	}
+	for _, btn := range config.Buttons {
+		ids = append(ids, customButtonID(btn.Name))
+		commands[customButtonID(btn.Name)] = btn.Command
+	}
+
+	label, _ := gtk.LabelNew("Row")
+	label.SetHAlign(gtk.ALIGN_START)
+	grid.Attach(label, 0, 0, 1, 1)
+
+	combo, _ := gtk.ComboBoxTextNew()
+	for _, id := range ids {
+		combo.Append(id, id)
+	}
+	grid.Attach(combo, 1, 0, 1, 1)
+
+	entries := make(map[string]*gtk.Entry)
+	for i, trigger := range triggers {
+		label, _ := gtk.LabelNew(trigger)
+		label.SetHAlign(gtk.ALIGN_START)
+		grid.Attach(label, 0, i+1, 1, 1)
+
+		entry, _ := gtk.EntryNew()
+		entry.SetProperty("name", "edit-field")
+		entry.SetWidthChars(40)
+		grid.Attach(entry, 1, i+1, 1, 1)
+		entries[trigger] = entry
+	}
+
+	label, _ = gtk.LabelNew("A command, or: @mute, @volume +5, @brightness -5, @copy-ip, @lock, @suspend, @reboot…, @none")
+	label.SetHAlign(gtk.ALIGN_START)
+	grid.Attach(label, 0, len(triggers)+1, 2, 1)
+
+	current := ""
+	// Stores the entries of the row being edited
+	store := func() {
+		if current == "" {
+			return
+		}
+		delete(actions, current)
+		for trigger, entry := range entries {
+			text, _ := entry.GetText()
+			if text = strings.TrimSpace(text); text != "" {
+				if actions[current] == nil {
+					actions[current] = make(map[string]string)
+				}
+				actions[current][trigger] = text
+			}
+		}
+	}
+	combo.Connect("changed", func() {
+		store()
+		current = combo.GetActiveID()
+		for trigger, entry := range entries {
+			entry.SetText(actions[current][trigger])
+			placeholder := defaultActions[current][trigger]
+			if trigger == triggerLeft && commands[current] != "" {
+				placeholder = commands[current]
+			}
+			entry.SetPlaceholderText(placeholder)
+		}
+	})
+	combo.SetActive(0)
+
+	buttons, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	grid.Attach(buttons, 1, len(triggers)+2, 1, 1)
+
+	btn, _ := gtk.ButtonNew()
+	btn.SetLabel("Apply")
+	btn.Connect("clicked", func() {
+		store()
+		settings.Preferences.Actions = actions
+		win.Close()
+	})
+	buttons.PackEnd(btn, false, false, 0)
+
+	btn, _ = gtk.ButtonNew()
+	btn.SetLabel("Cancel")
+	btn.Connect("clicked", func() {
+		win.Close()
+	})
+	buttons.PackEnd(btn, false, false, 0)
+
+	win.Add(vbox)
+	win.ShowAll()
+}
+
 func setupIconsEditionWindow() {
 	win, _ := gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
 
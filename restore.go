@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Parts of user's config that may be restored to defaults
+var restoreComponents = []string{"preferences", "templates", "css", "icons", "cli"}
+
+// restoreItem pairs a default file with the user's file it restores
+type restoreItem struct {
+	asset   string
+	dst     string
+	layered bool // system-wide copies in $XDG_CONFIG_DIRS/nwgocc replace the default
+}
+
+// Returns files a component consists of
+func componentItems(component string) []restoreItem {
+	var items []restoreItem
+	switch component {
+	case "preferences":
+		items = append(items, restoreItem{"preferences.json", filepath.Join(dataDir(), "preferences.json"), false})
+	case "templates":
+		items = append(items, restoreItem{"config.json", filepath.Join(configDir(), *configFile), true})
+	case "css":
+		items = append(items, restoreItem{"style.css", filepath.Join(configDir(), profileCssFile()), true})
+	case "cli":
+		items = append(items, restoreItem{"cli_commands", filepath.Join(configDir(), "cli_commands"), true})
+	case "icons":
+		for _, dir := range []string{"icons_light", "icons_dark"} {
+			for _, name := range listAssets(dir) {
+				items = append(items, restoreItem{fmt.Sprintf("%s/%s", dir, name), filepath.Join(dataDir(), dir, name),
+					false})
+			}
+		}
+	}
+	return items
+}
+
+// Returns path to a system-wide copy the user's file overrides, or "" if none. Restoring such a file means
+// removing the user's copy, so that the system-wide one applies again.
+func systemCopy(item restoreItem) string {
+	if !item.layered {
+		return ""
+	}
+	for _, dir := range systemConfigDirs() {
+		path := filepath.Join(dir, filepath.Base(item.dst))
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// Parses comma-separated component names; "all" stands for all of them
+func parseComponents(s string) ([]string, error) {
+	var output []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if name == "all" {
+			return restoreComponents, nil
+		}
+		known := false
+		for _, c := range restoreComponents {
+			if c == name {
+				known = true
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown component '%s', expected one of: %s, all", name, strings.Join(restoreComponents, ", "))
+		}
+		output = append(output, name)
+	}
+	return output, nil
+}
+
+// Returns a human-readable summary of changes restoring given components would make, "" if none
+func restoreDiff(components []string) string {
+	var output []string
+	for _, component := range components {
+		for _, item := range componentItems(component) {
+			if system := systemCopy(item); system != "" {
+				current, err := ioutil.ReadFile(item.dst)
+				if err != nil {
+					continue
+				}
+				def, err := ioutil.ReadFile(system)
+				if err != nil {
+					continue
+				}
+				output = append(output, fmt.Sprintf("%s: will be removed, %s applies", item.dst, system))
+				if !bytes.Equal(current, def) {
+					output = append(output, fmt.Sprintf("--- %s\n+++ system-wide %s", item.dst, system))
+					output = append(output, diffLines(strings.Split(string(current), "\n"), strings.Split(string(def), "\n"))...)
+				}
+				continue
+			}
+			def, err := readAsset(item.asset)
+			if err != nil {
+				continue
+			}
+			current, err := ioutil.ReadFile(item.dst)
+			if err != nil {
+				output = append(output, fmt.Sprintf("%s: missing, will be created", item.dst))
+				continue
+			}
+			if bytes.Equal(current, def) {
+				continue
+			}
+			if component == "icons" {
+				output = append(output, fmt.Sprintf("%s: modified, will be replaced", item.dst))
+				continue
+			}
+			output = append(output, fmt.Sprintf("--- %s\n+++ default %s", item.dst, item.asset))
+			output = append(output, diffLines(strings.Split(string(current), "\n"), strings.Split(string(def), "\n"))...)
+		}
+	}
+	return strings.Join(output, "\n")
+}
+
+// Returns lines removed from `a` ("-") and added in `b` ("+"), based on the longest common subsequence
+func diffLines(a, b []string) []string {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var output []string
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			output = append(output, "-"+a[i])
+			i++
+		default:
+			output = append(output, "+"+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		output = append(output, "-"+a[i])
+	}
+	for ; j < len(b); j++ {
+		output = append(output, "+"+b[j])
+	}
+	return output
+}
+
+// Overwrites user's files of given components with defaults, or removes them if system-wide copies exist.
+// Files that differ from defaults are backed up to `$XDG_STATE_HOME/nwgocc/backup/<date-time>/` first.
+func restore(components []string) error {
+	backupDir := filepath.Join(stateDir(), "backup", time.Now().Format("20060102-150405"))
+	backUp := func(path string, content []byte) error {
+		backup := filepath.Join(backupDir, filepath.Base(filepath.Dir(path)), filepath.Base(path))
+		createDir(filepath.Dir(backup))
+		err := ioutil.WriteFile(backup, content, 0644)
+		if err == nil {
+			fmt.Println("Backup:", backup)
+		}
+		return err
+	}
+	for _, component := range components {
+		for _, item := range componentItems(component) {
+			if system := systemCopy(item); system != "" {
+				current, err := ioutil.ReadFile(item.dst)
+				if err != nil {
+					continue
+				}
+				def, _ := ioutil.ReadFile(system)
+				if !bytes.Equal(current, def) {
+					err = backUp(item.dst, current)
+					if err != nil {
+						return err
+					}
+				}
+				fmt.Println("Removing file:", item.dst)
+				err = os.Remove(item.dst)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			def, err := readAsset(item.asset)
+			if err != nil {
+				return err
+			}
+			current, err := ioutil.ReadFile(item.dst)
+			if err == nil {
+				if bytes.Equal(current, def) {
+					continue
+				}
+				err = backUp(item.dst, current)
+				if err != nil {
+					return err
+				}
+			}
+			createDir(filepath.Dir(item.dst))
+			fmt.Println("Restoring file:", item.dst)
+			err = ioutil.WriteFile(item.dst, def, 0644)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Prints changes and restores components given with `-r` / `-restore`, if confirmed or `-y` given
+func restoreFromCommandLine() {
+	s := *restoreList
+	if *restoreDefaults {
+		s = "all"
+	}
+	components, err := parseComponents(s)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	diff := restoreDiff(components)
+	if diff == "" {
+		fmt.Println("Nothing to restore: files match defaults")
+		return
+	}
+	fmt.Println(diff)
+	if !*assumeYes {
+		fmt.Print("Restore? [y/N] ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.ToLower(strings.TrimSpace(answer)) != "y" {
+			fmt.Println("Nothing restored (use -y to restore without asking)")
+			return
+		}
+	}
+	err = restore(components)
+	check(err)
+}
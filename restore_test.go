@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []string
+	}{
+		{"equal", "a\nb", "a\nb", nil},
+		{"line added", "a\nc", "a\nb\nc", []string{"+b"}},
+		{"line removed", "a\nb\nc", "a\nc", []string{"-b"}},
+		{"line changed", "a\nb\nc", "a\nx\nc", []string{"-b", "+x"}},
+		{"from empty", "", "a", []string{"-", "+a"}},
+		{"trailing lines", "a", "a\nb\nc", []string{"+b", "+c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffLines(strings.Split(tt.a, "\n"), strings.Split(tt.b, "\n"))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseComponents(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    []string
+		wantErr bool
+	}{
+		{"css", []string{"css"}, false},
+		{"css, icons,", []string{"css", "icons"}, false},
+		{"css,all", restoreComponents, false},
+		{"themes", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseComponents(tt.s)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseComponents(%q) = %q, %v", tt.s, got, err)
+		}
+	}
+}
+
+func TestRestoreTemplatesOverSystemCopy(t *testing.T) {
+	home, system, state := t.TempDir(), t.TempDir(), t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+	t.Setenv("XDG_CONFIG_DIRS", system)
+	t.Setenv("XDG_STATE_HOME", state)
+	user := filepath.Join(home, "nwgocc", "config.json")
+	systemWide := filepath.Join(system, "nwgocc", "config.json")
+	for path, content := range map[string]string{user: `{"buttons": []}`, systemWide: `{"custom_rows": []}`} {
+		createDir(filepath.Dir(path))
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if diff := restoreDiff([]string{"templates"}); !strings.Contains(diff, "will be removed") {
+		t.Errorf("diff: got %q", diff)
+	}
+	if err := restore([]string{"templates"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(user); !os.IsNotExist(err) {
+		t.Errorf("user's copy not removed: %v", err)
+	}
+	if content, _ := os.ReadFile(systemWide); string(content) != `{"custom_rows": []}` {
+		t.Errorf("system-wide copy changed: %s", content)
+	}
+	backups, _ := filepath.Glob(filepath.Join(state, "nwgocc", "backup", "*", "nwgocc", "config.json"))
+	if len(backups) != 1 {
+		t.Errorf("backups: got %q", backups)
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// Row IDs, as used in rules; custom rows and buttons are identified by their names, see customRowID
+// and customButtonID
+const (
+	rowCli        = "cli"
+	rowBrightness = "brightness"
+	rowVolume     = "volume"
+	rowUser       = "user"
+	rowWifi       = "wifi"
+	rowInterface  = "interface"
+	rowBluetooth  = "bluetooth"
+	rowBattery    = "battery"
+	rowPower      = "power"
+)
+
+// Rows and buttons present in the window, by ID, for rules to show / hide them
+var rowWidgets map[string]gtk.IWidget
+
+// Box the rows are packed in, and separators between them by native pointer, to hide separators
+// left with no visible rows around
+var (
+	rowsBox       *gtk.Box
+	rowSeparators map[uintptr]bool
+)
+
+// ID of a custom row, apart from built-in rows and buttons, e.g. "row:Setup"
+func customRowID(name string) string {
+	return "row:" + name
+}
+
+// ID of a custom button, e.g. "button:Exit"
+func customButtonID(name string) string {
+	return "button:" + name
+}
+
+// Profile selected by rules on last evaluation
+var ruleProfile string
+
+// Rule selects a profile and / or hides rows if all its conditions are met
+type Rule struct {
+	Name    string     `json:"name"`
+	If      Conditions `json:"if"`
+	Profile string     `json:"profile,omitempty"`
+	Hide    []string   `json:"hide,omitempty"`
+}
+
+// Conditions of a rule; empty ones are not checked. Values may be shell patterns (e.g. "wlp*"),
+// and a value prefixed with "!" matches if the pattern does not.
+type Conditions struct {
+	Power     string `json:"power,omitempty"`     // "ac" or "battery"
+	Ssid      string `json:"ssid,omitempty"`      // connected Wi-Fi network, "" if none
+	Interface string `json:"interface,omitempty"` // interface of the default route
+	Hostname  string `json:"hostname,omitempty"`
+	Time      string `json:"time,omitempty"`      // "HH:MM-HH:MM", may span midnight
+	Backlight string `json:"backlight,omitempty"` // "yes" if a backlight device found, "no" otherwise
+}
+
+// ruleContext lazily gathers values conditions are checked against, so that we only run
+// the commands some rule needs
+type ruleContext struct {
+	values map[string]string
+}
+
+func (c *ruleContext) get(key string) string {
+	if c.values == nil {
+		c.values = make(map[string]string)
+	}
+	if v, ok := c.values[key]; ok {
+		return v
+	}
+	v := ""
+	switch key {
+	case "power":
+		if onAcPower() {
+			v = "ac"
+		} else {
+			v = "battery"
+		}
+	case "ssid":
+		v = getCommandOutput(settings.Commands.GetSsid)
+	case "interface":
+		v = defaultInterface()
+	case "hostname":
+		v, _ = os.Hostname()
+	case "backlight":
+		if hasBacklight() {
+			v = "yes"
+		} else {
+			v = "no"
+		}
+	}
+	c.values[key] = v
+
+	return v
+}
+
+// Checks a value against a (possibly negated) pattern
+func matchValue(pattern, value string) bool {
+	negate := strings.HasPrefix(pattern, "!")
+	pattern = strings.TrimPrefix(pattern, "!")
+	matched, err := filepath.Match(pattern, value)
+	if err != nil {
+		fmt.Println(err)
+		return false
+	}
+	return matched != negate
+}
+
+// Checks if now is within a "HH:MM-HH:MM" range
+func matchTime(timeRange string, now time.Time) bool {
+	negate := strings.HasPrefix(timeRange, "!")
+	parts := strings.Split(strings.TrimPrefix(timeRange, "!"), "-")
+	if len(parts) != 2 {
+		fmt.Printf("Invalid time range: '%s'\n", timeRange)
+		return false
+	}
+	from, err1 := time.Parse("15:04", strings.TrimSpace(parts[0]))
+	to, err2 := time.Parse("15:04", strings.TrimSpace(parts[1]))
+	if err1 != nil || err2 != nil {
+		fmt.Printf("Invalid time range: '%s'\n", timeRange)
+		return false
+	}
+	m := now.Hour()*60 + now.Minute()
+	f := from.Hour()*60 + from.Minute()
+	t := to.Hour()*60 + to.Minute()
+	var within bool
+	if f <= t {
+		within = m >= f && m < t
+	} else {
+		within = m >= f || m < t
+	}
+	return within != negate
+}
+
+func (r Rule) matches(c *ruleContext) bool {
+	checks := map[string]string{
+		"power":     r.If.Power,
+		"ssid":      r.If.Ssid,
+		"interface": r.If.Interface,
+		"hostname":  r.If.Hostname,
+		"backlight": r.If.Backlight,
+	}
+	for key, pattern := range checks {
+		if pattern != "" && !matchValue(pattern, c.get(key)) {
+			return false
+		}
+	}
+	if r.If.Time != "" && !matchTime(r.If.Time, time.Now()) {
+		return false
+	}
+	return true
+}
+
+// Evaluates rules; returns the profile selected by the last matching rule that sets one,
+// and IDs (or patterns) of rows to hide
+func evaluateRules() (string, []string) {
+	var c ruleContext
+	profile := ""
+	var hide []string
+	for _, rule := range settings.Rules {
+		if rule.matches(&c) {
+			if rule.Profile != "" {
+				profile = rule.Profile
+			}
+			hide = append(hide, rule.Hide...)
+		}
+	}
+	return profile, hide
+}
+
+// Evaluates rules, switches profile if a rule says so, and shows / hides rows
+func applyRules() {
+	if len(settings.Rules) == 0 {
+		// sections may be empty anyway, e.g. power buttons
+		updateSeparators()
+		return
+	}
+	profile, hide := evaluateRules()
+
+	// Switch profile only if the rules result changed, so that we don't override user's manual choice
+	if profile != ruleProfile {
+		ruleProfile = profile
+		if profile != "" && profile != *configFile && !isFlagSet("c") {
+			fmt.Printf("Rules: switching to '%s'\n", profile)
+			switchProfile(profile)
+			return
+		}
+	}
+
+	for id, widget := range rowWidgets {
+		hidden := false
+		for _, pattern := range hide {
+			if matched, _ := filepath.Match(pattern, id); matched {
+				hidden = true
+			}
+		}
+		widget.ToWidget().SetVisible(!hidden)
+	}
+	updateSeparators()
+}
+
+// Packs a separator between rows
+func packSeparator(box *gtk.Box) {
+	sep, _ := gtk.SeparatorNew(gtk.ORIENTATION_HORIZONTAL)
+	rowSeparators[sep.Native()] = true
+	box.PackStart(sep, true, true, 6)
+}
+
+// Shows separators that have visible rows on both sides, one at most between two rows, and hides others
+func updateSeparators() {
+	if rowsBox == nil {
+		return
+	}
+	var widgets []*gtk.Widget
+	var separator, visible []bool
+	rowsBox.GetChildren().Foreach(func(item interface{}) {
+		widget := item.(*gtk.Widget)
+		widgets = append(widgets, widget)
+		separator = append(separator, rowSeparators[widget.Native()])
+		visible = append(visible, widget.GetVisible())
+	})
+	for i, show := range shownSeparators(separator, visible) {
+		if separator[i] {
+			widgets[i].SetVisible(show)
+		}
+	}
+}
+
+// Given which items are separators and which other items are visible, tells which separators to show:
+// the last one of each run between two visible items
+func shownSeparators(separator, visible []bool) []bool {
+	show := make([]bool, len(separator))
+	pending := -1
+	itemAbove := false
+	for i := range separator {
+		if separator[i] {
+			if itemAbove {
+				pending = i
+			}
+			continue
+		}
+		if !visible[i] {
+			continue
+		}
+		if pending >= 0 {
+			show[pending] = true
+			pending = -1
+		}
+		itemAbove = true
+	}
+	return show
+}
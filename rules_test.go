@@ -0,0 +1,93 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMatchValue(t *testing.T) {
+	tests := []struct {
+		pattern, value string
+		want           bool
+	}{
+		{"ac", "ac", true},
+		{"ac", "battery", false},
+		{"office*", "office-5G", true},
+		{"office*", "home", false},
+		{"!office*", "home", true},
+		{"!office*", "office", false},
+		{"", "", true},
+		{"!", "", false},
+		{"wlp?s0", "wlp2s0", true},
+		{"[", "x", false},
+	}
+	for _, tt := range tests {
+		if got := matchValue(tt.pattern, tt.value); got != tt.want {
+			t.Errorf("matchValue(%q, %q) = %t, want %t", tt.pattern, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestMatchTime(t *testing.T) {
+	at := func(hour, min int) time.Time {
+		return time.Date(2024, 1, 1, hour, min, 0, 0, time.Local)
+	}
+	tests := []struct {
+		timeRange string
+		now       time.Time
+		want      bool
+	}{
+		{"08:00-16:00", at(8, 0), true},
+		{"08:00-16:00", at(12, 30), true},
+		{"08:00-16:00", at(16, 0), false},
+		{"08:00-16:00", at(7, 59), false},
+		{"22:00-06:00", at(23, 0), true},
+		{"22:00-06:00", at(3, 0), true},
+		{"22:00-06:00", at(12, 0), false},
+		{"!08:00-16:00", at(12, 0), false},
+		{"!08:00-16:00", at(20, 0), true},
+		{" 08:00 - 16:00 ", at(9, 0), true},
+		{"08:00", at(8, 0), false},
+		{"8am-4pm", at(9, 0), false},
+	}
+	for _, tt := range tests {
+		if got := matchTime(tt.timeRange, tt.now); got != tt.want {
+			t.Errorf("matchTime(%q, %s) = %t, want %t", tt.timeRange, tt.now.Format("15:04"), got, tt.want)
+		}
+	}
+}
+
+func TestShownSeparators(t *testing.T) {
+	// s: separator, v: visible item, h: hidden item
+	parse := func(layout string) ([]bool, []bool) {
+		var separator, visible []bool
+		for _, c := range layout {
+			separator = append(separator, c == 's')
+			visible = append(visible, c == 'v')
+		}
+		return separator, visible
+	}
+	tests := []struct {
+		layout string
+		want   string // positions of separators shown
+	}{
+		{"vsvsv", ".x.x."},
+		{"vshsv", "...x."},
+		{"hsvsv", "...x."},
+		{"vsvsh", ".x..."},
+		{"vssv", "..x."},
+		{"svs", "..."},
+		{"hshsh", "....."},
+	}
+	for _, tt := range tests {
+		separator, visible := parse(tt.layout)
+		var want []bool
+		for _, c := range tt.want {
+			want = append(want, c == 'x')
+		}
+		if got := shownSeparators(separator, visible); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %s", tt.layout, got, tt.want)
+		}
+	}
+}
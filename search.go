@@ -0,0 +1,261 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// maxSearchResults limits the number of results shown
+const maxSearchResults = 10
+
+// searchMatch is a custom row, button or application matching the search phrase
+type searchMatch struct {
+	name    string
+	cmd     string
+	icon    string
+	action  string // what a click on the row or button would run
+	confirm string // confirmation message, if it needs confirmation
+	score   int
+}
+
+var (
+	searchBox      *gtk.Box // search entry and results, shown while searching
+	searchEntry    *gtk.SearchEntry
+	searchResults  *gtk.Box
+	mainContent    *gtk.Box // regular content, hidden while searching
+	searchMatches  []searchMatch
+	searchSelected int
+	desktopEntries []desktopEntry // loaded on first search, if enabled
+)
+
+// Returns 0 if all the query characters don't appear in the text in order; otherwise the higher, the better:
+// substrings score above scattered characters, and a match at the beginning scores best
+func fuzzyScore(query, text string) int {
+	query, text = strings.ToLower(query), strings.ToLower(text)
+	if query == "" || text == "" {
+		return 0
+	}
+	if i := strings.Index(text, query); i >= 0 {
+		score := 100 - i
+		if score < 50 {
+			score = 50
+		}
+		if i == 0 {
+			score += 50
+		}
+		return score
+	}
+
+	// characters in order, with gaps
+	gaps, pos := 0, 0
+	runes := []rune(text)
+	for _, q := range query {
+		found := false
+		for pos < len(runes) {
+			pos++
+			if runes[pos-1] == q {
+				found = true
+				break
+			}
+			gaps++
+		}
+		if !found {
+			return 0
+		}
+	}
+	score := 40 - gaps
+	if score < 1 {
+		score = 1
+	}
+	return score
+}
+
+// Name matches count twice as much as command matches
+func matchScore(query, name, cmd string) int {
+	score := fuzzyScore(query, name) * 2
+	if s := fuzzyScore(query, cmd); s > score {
+		score = s
+	}
+	return score
+}
+
+// Returns custom rows, buttons and (optionally) applications matching the query, best first
+func findMatches(query string) []searchMatch {
+	var matches []searchMatch
+	add := func(match searchMatch) {
+		if match.action == "" {
+			return
+		}
+		if match.score = matchScore(query, match.name, match.cmd); match.score > 0 {
+			matches = append(matches, match)
+		}
+	}
+
+	if settings.Preferences.ShowUserRows {
+		for _, row := range config.CustomRows {
+			add(searchMatch{name: row.Name, cmd: row.Command, icon: row.Icon,
+				action:  rowActions(customRowID(row.Name), row.Command, row.Actions)[triggerLeft],
+				confirm: confirmMessage(row.Confirm, row.Message, row.Name)})
+		}
+	}
+	if settings.Preferences.ShowUserButtons {
+		for _, btn := range config.Buttons {
+			add(searchMatch{name: btn.Name, cmd: btn.Command, icon: btn.Icon,
+				action:  rowActions(customButtonID(btn.Name), btn.Command, btn.Actions)[triggerLeft],
+				confirm: confirmMessage(btn.Confirm, btn.Message, btn.Name)})
+		}
+	}
+	if settings.Preferences.SearchDesktopApps {
+		if desktopEntries == nil {
+			desktopEntries = listDesktopEntries()
+		}
+		for _, entry := range desktopEntries {
+			// we have no terminal to run them in
+			if !entry.Terminal {
+				cmd := desktopCommand(entry.Exec)
+				add(searchMatch{name: entry.Name, cmd: cmd, icon: entry.Icon, action: cmd})
+			}
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+	if len(matches) > maxSearchResults {
+		matches = matches[:maxSearchResults]
+	}
+	return matches
+}
+
+// Builds the search entry and results box, hidden until the user starts typing
+func setupSearch() *gtk.Box {
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 6)
+	box.SetNoShowAll(true)
+
+	searchEntry, _ = gtk.SearchEntryNew()
+	searchEntry.SetPlaceholderText("Search")
+	searchEntry.Connect("search-changed", func() {
+		text, _ := searchEntry.GetText()
+		if text == "" {
+			closeSearch()
+			return
+		}
+		searchMatches = findMatches(text)
+		searchSelected = 0
+		showSearchResults()
+	})
+	box.PackStart(searchEntry, false, false, 0)
+	searchEntry.Show()
+
+	searchResults, _ = gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 0)
+	box.PackStart(searchResults, false, false, 0)
+	searchResults.Show()
+
+	return box
+}
+
+func searchActive() bool {
+	return searchBox != nil && searchBox.GetVisible()
+}
+
+// Shows the search entry, with the first character typed
+func openSearch(text string) {
+	if searchBox == nil {
+		return
+	}
+	mainContent.Hide()
+	searchBox.Show()
+	searchEntry.SetText(text)
+	searchEntry.GrabFocusWithoutSelecting()
+	searchEntry.SetPosition(-1)
+}
+
+// Clears and hides the search, bringing the regular content back
+func closeSearch() {
+	if !searchActive() {
+		return
+	}
+	searchBox.Hide()
+	mainContent.Show()
+	searchMatches = nil
+	searchEntry.SetText("")
+}
+
+// Rebuilds the list of results, with the selected one highlighted
+func showSearchResults() {
+	children := searchResults.GetChildren()
+	children.Foreach(func(item interface{}) {
+		item.(*gtk.Widget).Destroy()
+	})
+
+	if len(searchMatches) == 0 {
+		label, _ := gtk.LabelNew("No matches")
+		searchResults.PackStart(label, false, false, 4)
+	}
+	for i, match := range searchMatches {
+		row := setupSearchResult(match, i == searchSelected)
+		searchResults.PackStart(row, false, false, 4)
+	}
+	searchResults.ShowAll()
+}
+
+// A result row looks like a custom row
+func setupSearchResult(match searchMatch, selected bool) *gtk.EventBox {
+	eventBox, _ := gtk.EventBoxNew()
+	styleContext, _ := eventBox.GetStyleContext()
+	hBox, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 10)
+
+	if match.icon != "" {
+		pixbuf := createPixbuf(match.icon, settings.Preferences.IconSizeSmall)
+		image, _ := gtk.ImageNewFromPixbuf(pixbuf)
+		hBox.PackStart(image, false, false, 2)
+	}
+	label, _ := gtk.LabelNew(match.name)
+	hBox.PackStart(label, false, false, 2)
+
+	if settings.Preferences.CustomStyling {
+		if selected {
+			hBox.SetProperty("name", "row-selected")
+		} else {
+			hBox.SetProperty("name", "row-normal")
+		}
+	} else if selected {
+		styleContext.SetState(gtk.STATE_FLAG_SELECTED)
+	}
+
+	eventBox.Connect("button-press-event", func() {
+		closeSearch()
+		confirmOrRun(match.confirm, match.action)
+	})
+	eventBox.Add(hBox)
+
+	return eventBox
+}
+
+// Up / Down select a result, Enter activates the selected one (the top match by default) as a click would;
+// other keys go to the search entry
+func handleSearchKey(keyVal uint) bool {
+	switch keyVal {
+	case gdk.KEY_Down, gdk.KEY_Up:
+		if len(searchMatches) > 0 {
+			if keyVal == gdk.KEY_Down {
+				searchSelected = (searchSelected + 1) % len(searchMatches)
+			} else {
+				searchSelected = (searchSelected + len(searchMatches) - 1) % len(searchMatches)
+			}
+			showSearchResults()
+		}
+		return true
+	case gdk.KEY_Return, gdk.KEY_KP_Enter:
+		if searchSelected < len(searchMatches) {
+			match := searchMatches[searchSelected]
+			closeSearch()
+			confirmOrRun(match.confirm, match.action)
+		}
+		return true
+	}
+	return false
+}
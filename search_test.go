@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFuzzyScore(t *testing.T) {
+	tests := []struct {
+		query, text string
+		want        int
+	}{
+		{"fire", "Firefox", 150},
+		{"fox", "Firefox", 96},
+		{"ff", "Firefox", 37},
+		{"fx", "Firefox", 35},
+		{"xf", "Firefox", 0},
+		{"chrome", "Firefox", 0},
+		{"", "Firefox", 0},
+		{"a", "", 0},
+		{"z", strings.Repeat("a", 60) + "z", 50},
+		{"az", "a" + "bcdefghijklmnopqrstuvwxy" + "bcdefghijklmnopqrstuvwxy" + "z", 1},
+	}
+	for _, tt := range tests {
+		if got := fuzzyScore(tt.query, tt.text); got != tt.want {
+			t.Errorf("fuzzyScore(%q, %q) = %d; want %d", tt.query, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestFuzzyScoreOrder(t *testing.T) {
+	// prefix above substring, above scattered characters
+	prefix := fuzzyScore("term", "terminal")
+	substring := fuzzyScore("term", "xterm")
+	scattered := fuzzyScore("term", "the remote")
+	if !(prefix > substring && substring > scattered && scattered > 0) {
+		t.Errorf("got prefix %d, substring %d, scattered %d", prefix, substring, scattered)
+	}
+}
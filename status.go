@@ -0,0 +1,383 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/itchyny/volume-go"
+)
+
+// Status is a snapshot of what built-in rows show; sections not asked for are nil
+type Status struct {
+	Battery    *BatteryStatus    `json:"battery,omitempty"`
+	Wifi       *WifiStatus       `json:"wifi,omitempty"`
+	Bluetooth  *BluetoothStatus  `json:"bt,omitempty"`
+	Net        *NetStatus        `json:"net,omitempty"`
+	Volume     *VolumeStatus     `json:"volume,omitempty"`
+	Brightness *BrightnessStatus `json:"brightness,omitempty"`
+}
+
+// BatteryStatus as read from `upower` or `acpi`
+type BatteryStatus struct {
+	Percentage int    `json:"percentage"`
+	Status     string `json:"status"`
+	Ac         bool   `json:"ac"`
+}
+
+// WifiStatus holds the connected network name, if any
+type WifiStatus struct {
+	Connected bool   `json:"connected"`
+	Ssid      string `json:"ssid"`
+}
+
+// BluetoothStatus holds the controller state, and names of connected devices
+type BluetoothStatus struct {
+	Powered bool     `json:"powered"`
+	Name    string   `json:"name"`
+	Devices []string `json:"devices,omitempty"`
+}
+
+// NetStatus holds state of the interface selected in preferences, and of the default route one
+type NetStatus struct {
+	Interface        string `json:"interface"`
+	Up               bool   `json:"up"`
+	Address          string `json:"address"`
+	DefaultInterface string `json:"default_interface"`
+}
+
+// VolumeStatus holds the default audio output state
+type VolumeStatus struct {
+	Volume int  `json:"volume"`
+	Muted  bool `json:"muted"`
+}
+
+// BrightnessStatus holds the backlight level
+type BrightnessStatus struct {
+	Brightness int `json:"brightness"`
+}
+
+// Status sections, as used on the command line
+var statusSections = []string{"battery", "wifi", "bt", "net", "volume", "brightness"}
+
+// Copy of settings for status getters running outside the GTK main loop, which changes `settings`
+// on reload and in the Preferences window
+var (
+	settingsSnapshotMutex sync.Mutex
+	settingsSnapshotCopy  Settings
+)
+
+// Copies settings for use in goroutines; to be called in the main loop after settings got (re)loaded
+func syncSettingsSnapshot() {
+	settingsSnapshotMutex.Lock()
+	settingsSnapshotCopy = settings
+	settingsSnapshotMutex.Unlock()
+}
+
+// Returns the copy of settings made on last syncSettingsSnapshot call
+func settingsSnapshot() Settings {
+	settingsSnapshotMutex.Lock()
+	defer settingsSnapshotMutex.Unlock()
+	return settingsSnapshotCopy
+}
+
+// Returns status text and percentage, from the first available battery command
+func readBattery(commands Commands) (string, int) {
+	if isCommand(commands.GetBattery) {
+		return getBattery(commands.GetBattery)
+	} else if isCommand(commands.GetBatteryAlt) {
+		return getBattery(commands.GetBatteryAlt)
+	}
+	return "", 0
+}
+
+func getBatteryStatus(commands Commands) *BatteryStatus {
+	status, val := readBattery(commands)
+	return &BatteryStatus{Percentage: val, Status: status, Ac: onAcPower()}
+}
+
+func getWifiStatus(commands Commands) *WifiStatus {
+	ssid := getCommandOutput(commands.GetSsid)
+	return &WifiStatus{Connected: ssid != "", Ssid: ssid}
+}
+
+// Returns the controller state; names of connected devices too, if asked for
+func getBluetoothStatus(commands Commands, devices bool) *BluetoothStatus {
+	s := &BluetoothStatus{Powered: getCommandOutput(commands.GetBluetoothStatus) == "yes"}
+	if s.Powered {
+		s.Name = getCommandOutput(commands.GetBluetoothName)
+	}
+	if s.Powered && devices {
+		for _, line := range strings.Split(getCommandOutput(commands.GetBluetoothDevices), "\n") {
+			if line != "" {
+				s.Devices = append(s.Devices, line)
+			}
+		}
+	}
+	return s
+}
+
+func getNetStatus(interfaceName string) *NetStatus {
+	s := &NetStatus{Interface: interfaceName, DefaultInterface: defaultInterface()}
+	if s.Interface != "" {
+		s.Up, s.Address = interfaceIsUp(s.Interface)
+	}
+	return s
+}
+
+func getVolumeStatus() *VolumeStatus {
+	vol, _ := volume.GetVolume()
+	muted, _ := volume.GetMuted()
+	return &VolumeStatus{Volume: vol, Muted: muted}
+}
+
+func getBrightnessStatus(commands Commands) *BrightnessStatus {
+	return &BrightnessStatus{Brightness: int(readBrightness(commands.GetBrightness))}
+}
+
+// Returns status of given sections (all of them if none given), as told by commands from the settings snapshot,
+// so that it may be called in a goroutine
+func getStatus(sections ...string) Status {
+	return readStatus(true, sections...)
+}
+
+// Same as getStatus, but Bluetooth devices (slow to get) only if asked for
+func readStatus(btDevices bool, sections ...string) Status {
+	if len(sections) == 0 {
+		sections = statusSections
+	}
+	snapshot := settingsSnapshot()
+	var s Status
+	for _, section := range sections {
+		switch section {
+		case "battery":
+			s.Battery = getBatteryStatus(snapshot.Commands)
+		case "wifi":
+			s.Wifi = getWifiStatus(snapshot.Commands)
+		case "bt":
+			s.Bluetooth = getBluetoothStatus(snapshot.Commands, btDevices)
+		case "net":
+			s.Net = getNetStatus(snapshot.Preferences.InterfaceName)
+		case "volume":
+			s.Volume = getVolumeStatus()
+		case "brightness":
+			s.Brightness = getBrightnessStatus(snapshot.Commands)
+		}
+	}
+	return s
+}
+
+// Calls back with status of given sections (all if none given) until the process ends: battery and Bluetooth devices
+// get refreshed every `refresh_slow_seconds`, the rest every `refresh_fast_millis`.
+func pollStatus(sections []string, callback func(Status)) {
+	if len(sections) == 0 {
+		sections = statusSections
+	}
+	// sections refreshed on the fast timer
+	var fast []string
+	for _, section := range sections {
+		if section != "battery" {
+			fast = append(fast, section)
+		}
+	}
+	snapshot := settingsSnapshot()
+	fastInterval := time.Duration(snapshot.Preferences.RefreshFastMillis) * time.Millisecond
+	slowInterval := time.Duration(snapshot.Preferences.RefreshSlowSeconds) * time.Second
+
+	status := getStatus(sections...)
+	slowRefreshed := time.Now()
+	for {
+		callback(status)
+		time.Sleep(fastInterval)
+
+		previous := status
+		slow := time.Since(slowRefreshed) >= slowInterval
+		if len(fast) > 0 {
+			status = readStatus(slow, fast...)
+		}
+		status.Battery = previous.Battery
+		if slow {
+			if status.Battery != nil {
+				status.Battery = getBatteryStatus(settingsSnapshot().Commands)
+			}
+			slowRefreshed = time.Now()
+		} else if status.Bluetooth != nil && status.Bluetooth.Powered && previous.Bluetooth != nil {
+			status.Bluetooth.Devices = previous.Bluetooth.Devices
+		}
+	}
+}
+
+// Functions called on every status poll with the previous and the current status; sections of the previous one are
+// nil on the first poll. Watchers get called in the GTK main loop.
+var statusWatchers []func(previous, current Status)
+
+// Starts polling status in the background, if anything watches it
+func watchStatus() {
+	if len(statusWatchers) == 0 {
+		return
+	}
+	go func() {
+		var previous Status
+		pollStatus(statusSections, func(current Status) {
+			last := previous
+			previous = current
+			glib.IdleAdd(func() {
+				for _, watcher := range statusWatchers {
+					watcher(last, current)
+				}
+			})
+		})
+	}()
+}
+
+// Handles `nwgocc status [section...] [--json]`. Runs without initializing GTK, prints status of given sections
+// (all by default) as text, or as a JSON document.
+func statusCommand(args []string) {
+	asJSON := false
+	var sections []string
+	for _, arg := range args {
+		switch arg {
+		case "--json", "-json", "-j":
+			asJSON = true
+		case "all":
+			sections = statusSections
+		case "-h", "--help":
+			fmt.Printf("Usage: nwgocc status [%s|all] [--json]\n", strings.Join(statusSections, "|"))
+			os.Exit(0)
+		default:
+			checkSection(arg)
+			sections = append(sections, arg)
+		}
+	}
+
+	loadSettingsOrDefaults()
+	status := getStatus(sections...)
+
+	if asJSON {
+		bytes, _ := json.MarshalIndent(status, "", "  ")
+		fmt.Println(string(bytes))
+		return
+	}
+	fmt.Print(status.String())
+}
+
+// Exits with a message if given name is not a known status section
+func checkSection(name string) {
+	for _, section := range statusSections {
+		if name == section {
+			return
+		}
+	}
+	fmt.Printf("Unknown section '%s', expected one of: %s, all\n", name, strings.Join(statusSections, ", "))
+	os.Exit(1)
+}
+
+// Loads user's preferences.json, or the default one if not found
+func loadSettingsOrDefaults() {
+	var err error
+	settings, err = loadSettings()
+	if err != nil {
+		bytes, err := readAsset("preferences.json")
+		check(err)
+		err = json.Unmarshal(bytes, &settings)
+		check(err)
+	}
+	checkMissingSettings()
+	syncSettingsSnapshot()
+}
+
+// Returns status as human-readable lines
+func (s Status) String() string {
+	var lines []string
+	if s.Battery != nil {
+		power := "battery"
+		if s.Battery.Ac {
+			power = "ac"
+		}
+		lines = append(lines, fmt.Sprintf("battery: %d%% (%s) %s", s.Battery.Percentage, power, s.Battery.Status))
+	}
+	if s.Wifi != nil {
+		if s.Wifi.Connected {
+			lines = append(lines, fmt.Sprintf("wifi: %s", s.Wifi.Ssid))
+		} else {
+			lines = append(lines, "wifi: disconnected")
+		}
+	}
+	if s.Bluetooth != nil {
+		if s.Bluetooth.Powered {
+			lines = append(lines, fmt.Sprintf("bt: %s", s.Bluetooth.Name))
+		} else {
+			lines = append(lines, "bt: disabled")
+		}
+	}
+	if s.Net != nil {
+		line := fmt.Sprintf("net: default route via '%s'", s.Net.DefaultInterface)
+		if s.Net.Interface != "" {
+			if s.Net.Up {
+				line = fmt.Sprintf("%s, %s: %s", line, s.Net.Interface, s.Net.Address)
+			} else {
+				line = fmt.Sprintf("%s, %s: down", line, s.Net.Interface)
+			}
+		}
+		lines = append(lines, line)
+	}
+	if s.Volume != nil {
+		if s.Volume.Muted {
+			lines = append(lines, fmt.Sprintf("volume: %d%% (muted)", s.Volume.Volume))
+		} else {
+			lines = append(lines, fmt.Sprintf("volume: %d%%", s.Volume.Volume))
+		}
+	}
+	if s.Brightness != nil {
+		lines = append(lines, fmt.Sprintf("brightness: %d%%", s.Brightness.Brightness))
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Returns the icon name for given battery percentage
+func batteryIcon(val int) string {
+	switch {
+	case val > 95:
+		return settings.Icons.BatteryFull
+	case val > 50:
+		return settings.Icons.BatteryGood
+	case val > 20:
+		return settings.Icons.BatteryLow
+	default:
+		return settings.Icons.BatteryEmpty
+	}
+}
+
+// Returns the icon name for given brightness level
+func brightnessIcon(bri int) string {
+	switch {
+	case bri > 70:
+		return settings.Icons.BrightnessHigh
+	case bri > 30:
+		return settings.Icons.BrightnessMedium
+	default:
+		return settings.Icons.BrightnessLow
+	}
+}
+
+// Returns the icon name for given volume level
+func volumeIcon(vol int, muted bool) string {
+	if muted {
+		return settings.Icons.VolumeMuted
+	}
+	switch {
+	case vol > 70:
+		return settings.Icons.VolumeHigh
+	case vol > 30:
+		return settings.Icons.VolumeMedium
+	default:
+		return settings.Icons.VolumeLow
+	}
+}
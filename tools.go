@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"math"
 	"net"
@@ -13,6 +14,7 @@ import (
 	"github.com/gotk3/gotk3/gdk"
 	"github.com/gotk3/gotk3/glib"
 	"github.com/gotk3/gotk3/gtk"
+	"github.com/itchyny/volume-go"
 )
 
 func check(e error) {
@@ -21,6 +23,17 @@ func check(e error) {
 	}
 }
 
+// Returns true if the flag was given on the command line
+func isFlagSet(name string) bool {
+	found := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
+}
+
 func isWayland() bool {
 	return os.Getenv("XDG_SESSION_TYPE") == "wayland" || os.Getenv("WAYLAND_DISPLAY") != ""
 }
@@ -71,13 +84,27 @@ func createPixbuf(icon string, size int) *gdk.Pixbuf {
 	return pixbuf
 }
 
-func launchCommand(command string) {
-	elements := strings.Split(command, " ")
-	cmd := exec.Command(elements[0], elements[1:]...)
+// Runs the command with `sh -c` in the background; env entries ("KEY=value") get added to the environment
+func runShellCommand(command string, env ...string) {
+	cmd := exec.Command("sh", "-c", command)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
 	go cmd.Run()
+}
+
+// Runs the command of a row, button or action with `sh -c`, so that quoting works, and closes the window unless
+// told not to
+func launchCommand(command string) {
+	runShellCommand(command)
+	closeAfterLaunch()
+}
+
+// Closes the window after a command has been launched, unless told not to
+func closeAfterLaunch() {
 	if !settings.Preferences.DontClose {
 		glib.TimeoutAdd(uint(100), func() bool {
-			gtk.MainQuit()
+			closeWindow()
 			return false
 		})
 
@@ -139,8 +166,13 @@ func getBattery(command string) (string, int) {
 }
 
 func getBrightness() float64 {
+	return readBrightness(settings.Commands.GetBrightness)
+}
+
+// Returns brightness level as told by the command
+func readBrightness(command string) float64 {
 	brightness := 0.0
-	output := getCommandOutput(settings.Commands.GetBrightness)
+	output := getCommandOutput(command)
 	bri, e := strconv.ParseFloat(output, 64)
 	if e == nil {
 		brightness = math.Round(bri)
@@ -154,6 +186,62 @@ func setBrightness(value int) {
 	cmd.Run()
 }
 
+// Returns a new level for a value like "50" (absolute), "+5" or "-5" (relative to current), within 0-100
+func parseLevel(value string, current int) (int, error) {
+	v, err := strconv.Atoi(strings.TrimPrefix(value, "+"))
+	if err != nil {
+		return current, fmt.Errorf("invalid value '%s'", value)
+	}
+	level := v
+	if strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-") {
+		level = current + v
+	}
+	if level < 0 {
+		level = 0
+	} else if level > 100 {
+		level = 100
+	}
+	return level, nil
+}
+
+// Sets or adjusts the volume: "50", "+5", "-5", "mute", "unmute" or "toggle-mute"
+func adjustVolume(value string) error {
+	switch value {
+	case "mute":
+		return volume.Mute()
+	case "unmute":
+		return volume.Unmute()
+	case "toggle-mute":
+		muted, err := volume.GetMuted()
+		if err != nil {
+			return err
+		}
+		if muted {
+			return volume.Unmute()
+		}
+		return volume.Mute()
+	}
+	vol, err := volume.GetVolume()
+	if err != nil {
+		return err
+	}
+	level, err := parseLevel(value, vol)
+	if err != nil {
+		return err
+	}
+	return volume.SetVolume(level)
+}
+
+// Sets or adjusts the brightness: "50", "+5" or "-5"
+func adjustBrightness(value string) error {
+	level, err := parseLevel(value, int(getBrightness()))
+	if err != nil {
+		return err
+	}
+	setBrightness(level)
+	return nil
+}
+
 func listInterfaces() []string {
 	var list []string
 
@@ -188,3 +276,50 @@ func interfaceIsUp(name string) (bool, string) {
 
 	return false, ""
 }
+
+// Returns true if a mains power supply is online, or if there is no mains supply info (e.g. on a desktop)
+func onAcPower() bool {
+	supplies, _ := filepath.Glob("/sys/class/power_supply/*")
+	found := false
+	for _, supply := range supplies {
+		t, err := readTextFile(filepath.Join(supply, "type"))
+		if err != nil || strings.TrimSpace(t) != "Mains" {
+			continue
+		}
+		found = true
+		online, err := readTextFile(filepath.Join(supply, "online"))
+		if err == nil && strings.TrimSpace(online) == "1" {
+			return true
+		}
+	}
+	return !found
+}
+
+// Returns name of the first wireless interface, or "" if none
+func wifiInterface() string {
+	devices, _ := filepath.Glob("/sys/class/net/*/wireless")
+	if len(devices) == 0 {
+		return ""
+	}
+	return filepath.Base(filepath.Dir(devices[0]))
+}
+
+// Returns name of the interface the default route goes through, or "" if none
+func defaultInterface() string {
+	route, err := readTextFile("/proc/net/route")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(route, "\n")[1:] {
+		fields := strings.Fields(line)
+		if len(fields) > 1 && fields[1] == "00000000" {
+			return fields[0]
+		}
+	}
+	return ""
+}
+
+func hasBacklight() bool {
+	devices, _ := filepath.Glob("/sys/class/backlight/*")
+	return len(devices) > 0
+}
@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		value   string
+		current int
+		want    int
+		wantErr bool
+	}{
+		{"50", 20, 50, false},
+		{"+5", 20, 25, false},
+		{"-5", 20, 15, false},
+		{"+50", 80, 100, false},
+		{"-50", 20, 0, false},
+		{"150", 20, 100, false},
+		{"0", 20, 0, false},
+		{"", 20, 20, true},
+		{"+", 20, 20, true},
+		{"five", 20, 20, true},
+		{"5%", 20, 20, true},
+	}
+	for _, tt := range tests {
+		got, err := parseLevel(tt.value, tt.current)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("parseLevel(%q, %d) = %d, %v; want %d", tt.value, tt.current, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+	"github.com/gotk3/gotk3/glib"
+)
+
+// StatusNotifierItem and its menu (com.canonical.dbusmenu) object paths and interfaces
+const (
+	sniPath         = "/StatusNotifierItem"
+	sniInterface    = "org.kde.StatusNotifierItem"
+	sniWatcherName  = "org.kde.StatusNotifierWatcher"
+	sniWatcherPath  = "/StatusNotifierWatcher"
+	menuPath        = "/MenuBar"
+	menuInterface   = "com.canonical.dbusmenu"
+	trayMenuToggle  = 1
+	trayMenuRowsId  = 100
+	trayMenuBtnsId  = 1000
+	trayMenuSepRows = 2
+	trayMenuSepBtns = 3
+)
+
+var (
+	trayConn     *dbus.Conn
+	trayProps    *prop.Properties
+	trayName     string
+	trayMenu     []trayMenuItem
+	trayMenuRev  uint32
+	trayMenuLock sync.Mutex
+)
+
+// trayMenuItem is a tray menu entry: the toggle item, a separator, or a user row or button
+type trayMenuItem struct {
+	id        int32
+	label     string
+	icon      string
+	action    string // left click action of the row or button
+	confirm   string // its confirmation message, if it needs confirmation
+	separator bool
+}
+
+// sniToolTip is the StatusNotifierItem ToolTip property: (icon name, icon pixmaps, title, description)
+type sniToolTip struct {
+	IconName    string
+	IconPixmap  []sniPixmap
+	Title       string
+	Description string
+}
+
+type sniPixmap struct {
+	Width  int32
+	Height int32
+	Data   []byte
+}
+
+// menuLayout is a dbusmenu layout node: (id, properties, children)
+type menuLayout struct {
+	Id         int32
+	Properties map[string]dbus.Variant
+	Children   []dbus.Variant
+}
+
+type menuItemProperties struct {
+	Id         int32
+	Properties map[string]dbus.Variant
+}
+
+type menuEvent struct {
+	Id        int32
+	EventId   string
+	Data      dbus.Variant
+	Timestamp uint32
+}
+
+// trayItem methods get called by the tray host
+type trayItem struct{}
+
+// Activate is a primary (usually left) click
+func (trayItem) Activate(x, y int32) *dbus.Error {
+	glib.IdleAdd(toggleWindow)
+	return nil
+}
+
+// SecondaryActivate is a middle click
+func (trayItem) SecondaryActivate(x, y int32) *dbus.Error {
+	glib.IdleAdd(toggleWindow)
+	return nil
+}
+
+// ContextMenu gets only called by hosts that don't support dbusmenu
+func (trayItem) ContextMenu(x, y int32) *dbus.Error {
+	glib.IdleAdd(toggleWindow)
+	return nil
+}
+
+// Scroll adjusts volume
+func (trayItem) Scroll(delta int32, orientation string) *dbus.Error {
+	if orientation != "vertical" {
+		return nil
+	}
+	value := "+5"
+	if delta > 0 {
+		value = "-5"
+	}
+	glib.IdleAdd(func() {
+		err := adjustVolume(value)
+		if err == nil && volRow != nil {
+			updateVolumeRow()
+		}
+	})
+	return nil
+}
+
+// trayMenu methods implement com.canonical.dbusmenu
+type trayMenuService struct{}
+
+func menuItemProps(item trayMenuItem) map[string]dbus.Variant {
+	if item.separator {
+		return map[string]dbus.Variant{"type": dbus.MakeVariant("separator")}
+	}
+	props := map[string]dbus.Variant{"label": dbus.MakeVariant(item.label)}
+	if item.icon != "" && !strings.HasPrefix(item.icon, "/") {
+		props["icon-name"] = dbus.MakeVariant(item.icon)
+	}
+	return props
+}
+
+// GetLayout returns the whole menu: it's flat, so the depth doesn't matter
+func (trayMenuService) GetLayout(parentId int32, recursionDepth int32, propertyNames []string) (uint32, menuLayout, *dbus.Error) {
+	trayMenuLock.Lock()
+	defer trayMenuLock.Unlock()
+	root := menuLayout{Id: 0, Properties: map[string]dbus.Variant{
+		"children-display": dbus.MakeVariant("submenu")}, Children: []dbus.Variant{}}
+	for _, item := range trayMenu {
+		if parentId != 0 && parentId != item.id {
+			continue
+		}
+		child := menuLayout{Id: item.id, Properties: menuItemProps(item), Children: []dbus.Variant{}}
+		if parentId == item.id {
+			return trayMenuRev, child, nil
+		}
+		root.Children = append(root.Children, dbus.MakeVariant(child))
+	}
+	return trayMenuRev, root, nil
+}
+
+func (trayMenuService) GetGroupProperties(ids []int32, propertyNames []string) ([]menuItemProperties, *dbus.Error) {
+	trayMenuLock.Lock()
+	defer trayMenuLock.Unlock()
+	result := []menuItemProperties{}
+	for _, item := range trayMenu {
+		for _, id := range ids {
+			if id == item.id {
+				result = append(result, menuItemProperties{Id: item.id, Properties: menuItemProps(item)})
+			}
+		}
+	}
+	return result, nil
+}
+
+func (trayMenuService) GetProperty(id int32, name string) (dbus.Variant, *dbus.Error) {
+	trayMenuLock.Lock()
+	defer trayMenuLock.Unlock()
+	for _, item := range trayMenu {
+		if item.id == id {
+			if value, ok := menuItemProps(item)[name]; ok {
+				return value, nil
+			}
+		}
+	}
+	return dbus.MakeVariant(""), nil
+}
+
+func (trayMenuService) Event(id int32, eventId string, data dbus.Variant, timestamp uint32) *dbus.Error {
+	if eventId != "clicked" {
+		return nil
+	}
+	trayMenuLock.Lock()
+	defer trayMenuLock.Unlock()
+	for _, item := range trayMenu {
+		if item.id != id {
+			continue
+		}
+		if id == trayMenuToggle {
+			glib.IdleAdd(toggleWindow)
+		} else if item.action != "" {
+			item := item
+			glib.IdleAdd(func() {
+				runConfirmed(item.confirm, item.action)
+			})
+		}
+	}
+	return nil
+}
+
+func (s trayMenuService) EventGroup(events []menuEvent) ([]int32, *dbus.Error) {
+	for _, event := range events {
+		s.Event(event.Id, event.EventId, event.Data, event.Timestamp)
+	}
+	return []int32{}, nil
+}
+
+func (trayMenuService) AboutToShow(id int32) (bool, *dbus.Error) {
+	return false, nil
+}
+
+func (trayMenuService) AboutToShowGroup(ids []int32) ([]int32, []int32, *dbus.Error) {
+	return []int32{}, []int32{}, nil
+}
+
+// Rebuilds the tray menu from user rows and buttons of the current templates
+func updateTrayMenu() {
+	if trayConn == nil {
+		return
+	}
+	items := []trayMenuItem{{id: trayMenuToggle, label: "Show / hide"}}
+	if len(config.CustomRows) > 0 {
+		items = append(items, trayMenuItem{id: trayMenuSepRows, separator: true})
+		for i, row := range config.CustomRows {
+			items = append(items, trayMenuItem{id: int32(trayMenuRowsId + i), label: row.Name, icon: row.Icon,
+				action:  rowActions(customRowID(row.Name), row.Command, row.Actions)[triggerLeft],
+				confirm: confirmMessage(row.Confirm, row.Message, row.Name)})
+		}
+	}
+	if len(config.Buttons) > 0 {
+		items = append(items, trayMenuItem{id: trayMenuSepBtns, separator: true})
+		for i, btn := range config.Buttons {
+			items = append(items, trayMenuItem{id: int32(trayMenuBtnsId + i), label: btn.Name, icon: btn.Icon,
+				action:  rowActions(customButtonID(btn.Name), btn.Command, btn.Actions)[triggerLeft],
+				confirm: confirmMessage(btn.Confirm, btn.Message, btn.Name)})
+		}
+	}
+
+	trayMenuLock.Lock()
+	trayMenu = items
+	trayMenuRev++
+	rev := trayMenuRev
+	trayMenuLock.Unlock()
+	trayConn.Emit(menuPath, menuInterface+".LayoutUpdated", rev, int32(0))
+}
+
+// Returns the tray icon name for the status section selected with `-tray`
+func trayIcon(s Status) string {
+	hasBattery := s.Battery != nil && s.Battery.Status != ""
+	if s.Volume != nil && (*trayMode == "volume" || !hasBattery) {
+		return volumeIcon(s.Volume.Volume, s.Volume.Muted)
+	}
+	if hasBattery {
+		return batteryIcon(s.Battery.Percentage)
+	}
+	return "nwgocc"
+}
+
+// Updates the icon and tooltip, if changed
+func updateTray(s Status) {
+	icon := trayIcon(s)
+	if trayProps.GetMust(sniInterface, "IconName") != icon {
+		trayProps.SetMust(sniInterface, "IconName", icon)
+		trayConn.Emit(sniPath, sniInterface+".NewIcon")
+	}
+	description := strings.TrimSuffix(s.String(), "\n")
+	if trayProps.GetMust(sniInterface, "ToolTip").(sniToolTip).Description != description {
+		trayProps.SetMust(sniInterface, "ToolTip", sniToolTip{IconName: icon, IconPixmap: []sniPixmap{},
+			Title: "nwgocc", Description: description})
+		trayConn.Emit(sniPath, sniInterface+".NewToolTip")
+	}
+}
+
+// Registers our item with the StatusNotifierWatcher
+func registerTrayItem() error {
+	return trayConn.Object(sniWatcherName, sniWatcherPath).Call(sniWatcherName+".RegisterStatusNotifierItem", 0,
+		trayName).Err
+}
+
+// Exports a StatusNotifierItem with its menu on the session bus, and registers it with the tray host. Its icon
+// follows battery or volume state.
+func startTray() error {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return err
+	}
+	trayName = fmt.Sprintf("org.kde.StatusNotifierItem-%d-1", os.Getpid())
+	_, err = conn.RequestName(trayName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	iconThemePath := ""
+	if iconsDir != "" {
+		iconThemePath = iconsDir
+	}
+	item := trayItem{}
+	err = conn.Export(item, sniPath, sniInterface)
+	if err != nil {
+		return err
+	}
+	trayProps, err = prop.Export(conn, sniPath, prop.Map{sniInterface: {
+		"Category":      {Value: "Hardware", Emit: prop.EmitConst},
+		"Id":            {Value: "nwgocc", Emit: prop.EmitConst},
+		"Title":         {Value: "nwgocc: Control Center", Emit: prop.EmitConst},
+		"Status":        {Value: "Active", Emit: prop.EmitConst},
+		"WindowId":      {Value: int32(0), Emit: prop.EmitConst},
+		"IconName":      {Value: "nwgocc", Emit: prop.EmitFalse},
+		"IconThemePath": {Value: iconThemePath, Emit: prop.EmitConst},
+		"ToolTip":       {Value: sniToolTip{IconPixmap: []sniPixmap{}}, Emit: prop.EmitFalse},
+		"ItemIsMenu":    {Value: false, Emit: prop.EmitConst},
+		"Menu":          {Value: dbus.ObjectPath(menuPath), Emit: prop.EmitConst},
+	}})
+	if err != nil {
+		return err
+	}
+
+	menu := trayMenuService{}
+	err = conn.Export(menu, menuPath, menuInterface)
+	if err != nil {
+		return err
+	}
+	menuProps, err := prop.Export(conn, menuPath, prop.Map{menuInterface: {
+		"Version":       {Value: uint32(3), Emit: prop.EmitConst},
+		"TextDirection": {Value: "ltr", Emit: prop.EmitConst},
+		"Status":        {Value: "normal", Emit: prop.EmitConst},
+		"IconThemePath": {Value: []string{}, Emit: prop.EmitConst},
+	}})
+	if err != nil {
+		return err
+	}
+
+	for path, iface := range map[dbus.ObjectPath]introspect.Interface{
+		sniPath: {Name: sniInterface, Methods: introspect.Methods(item),
+			Properties: trayProps.Introspection(sniInterface),
+			Signals:    []introspect.Signal{{Name: "NewIcon"}, {Name: "NewToolTip"}}},
+		menuPath: {Name: menuInterface, Methods: introspect.Methods(menu),
+			Properties: menuProps.Introspection(menuInterface),
+			Signals: []introspect.Signal{{Name: "LayoutUpdated", Args: []introspect.Arg{
+				{Name: "revision", Type: "u"}, {Name: "parent", Type: "i"}}}}},
+	} {
+		node := &introspect.Node{Name: string(path), Interfaces: []introspect.Interface{
+			introspect.IntrospectData, prop.IntrospectData, iface}}
+		err = conn.Export(introspect.NewIntrospectable(node), path, "org.freedesktop.DBus.Introspectable")
+		if err != nil {
+			return err
+		}
+	}
+	trayConn = conn
+	updateTrayMenu()
+
+	// Register again if the tray host (re)starts
+	conn.AddMatchSignal(dbus.WithMatchInterface("org.freedesktop.DBus"), dbus.WithMatchMember("NameOwnerChanged"),
+		dbus.WithMatchArg(0, sniWatcherName))
+	signals := make(chan *dbus.Signal, 10)
+	conn.Signal(signals)
+	go func() {
+		for signal := range signals {
+			if signal.Name == "org.freedesktop.DBus.NameOwnerChanged" && len(signal.Body) == 3 &&
+				signal.Body[2] != "" {
+				registerTrayItem()
+			}
+		}
+	}()
+
+	statusWatchers = append(statusWatchers, func(previous, current Status) {
+		updateTray(current)
+	})
+
+	err = registerTrayItem()
+	if err != nil {
+		return fmt.Errorf("no tray host found: %s", err)
+	}
+	return nil
+}